@@ -0,0 +1,509 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/del1x/GoIMGtool/config"
+	"github.com/del1x/GoIMGtool/fileio"
+	"github.com/del1x/GoIMGtool/processor"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+const usageText = `Usage: goimgtool-cli <command> [flags]
+
+Commands:
+  process   watermark, resize and encode every image in a folder
+  resume    continue an interrupted run from the journal in its output folder
+  verify    read the invisible watermark from image files
+
+Run "goimgtool-cli <command> -h" for the flags of a command.
+`
+
+type processOptions struct {
+	input        string
+	watermark    string
+	format       string
+	maxWidth     int
+	maxHeight    int
+	maxQuality   int
+	targetSizeKB int
+	mode         string
+	workers      int
+	memBudgetMB  int
+	recursive    bool
+	maxDepth     int
+	hidden       bool
+	output       string
+	nameTemplate string
+	collision    string
+	incremental  bool
+	prune        bool
+	opacity      int
+	blendMode    string
+	adaptive     bool
+	variant      string
+	layers       layerFlag
+	portrait     string
+	landscape    string
+	square       string
+	anchor       string
+	margin       float64
+	marginUnit   string
+	scale        float64
+	tileSpacing  int
+	tileOffsetX  int
+	tileOffsetY  int
+	tileRotate   bool
+	text         string
+	font         string
+	textSize     float64
+	textColor    string
+	textEffect   string
+	forensic     string
+	recipients   string
+	resize       string
+	crop         string
+	pad          string
+	renditions   []config.Rendition
+}
+
+// Run executes the command line interface and returns the process exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usageText)
+		return exitUsage
+	}
+	switch args[0] {
+	case "process":
+		return runProcess(args[1:], stdout, stderr)
+	case "resume":
+		return runResume(args[1:], stdout, stderr)
+	case "verify":
+		return runVerify(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usageText)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usageText)
+		return exitUsage
+	}
+}
+
+func runProcess(args []string, stdout, stderr io.Writer) int {
+	opts, err := parseProcessFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	cfg := config.NewConfig(opts.maxWidth, opts.maxHeight, opts.format, 80).
+		WithTargetSize(opts.targetSizeKB).
+		WithMaxQuality(opts.maxQuality).
+		WithWorkers(opts.workers).
+		WithMemoryBudget(opts.memBudgetMB)
+	switch opts.resize {
+	case config.ResizeFill:
+		cfg.WithFill(opts.crop)
+	case config.ResizePad:
+		cfg.WithPad(opts.pad)
+	}
+	if opts.recursive {
+		cfg.WithRecursive(opts.maxDepth)
+	}
+	cfg.SkipHidden = !opts.hidden
+	cfg.WithOutput(opts.output, opts.nameTemplate).WithCollision(opts.collision)
+	if opts.incremental {
+		cfg.WithIncremental(opts.prune)
+	}
+	cfg.WithOpacity(opts.opacity).
+		WithBlendMode(opts.blendMode).
+		WithAnchor(opts.anchor, opts.margin, opts.marginUnit, opts.scale).
+		WithTile(opts.tileSpacing, opts.tileOffsetX, opts.tileOffsetY, opts.tileRotate).
+		WithText(opts.text, opts.font, opts.textSize, opts.textColor, opts.textEffect)
+	if opts.adaptive {
+		cfg.WithAdaptive(opts.variant)
+	}
+	cfg.WithOrientationWatermarks(opts.portrait, opts.landscape, opts.square).
+		WithLayers(opts.layers...).
+		WithForensic(opts.forensic).
+		WithRenditions(opts.renditions...)
+	if opts.recipients != "" {
+		recipients, err := config.LoadRecipients(opts.recipients)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitFailure
+		}
+		cfg.WithRecipients(recipients...)
+	}
+	var proc *processor.ImageProcessor
+	if opts.text != "" {
+		proc, err = processor.NewTextProcessor(cfg, &fileio.Handler{})
+	} else {
+		proc, err = processor.NewImageProcessor(opts.watermark, cfg, &fileio.Handler{})
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailure
+	}
+	proc.WatermarkMode = opts.mode
+
+	return runBatch(stdout, stderr, proc.OutputDirFor(opts.input), func(ctx context.Context) (*processor.BatchResult, error) {
+		return proc.ProcessFolderContext(ctx, opts.input, cfg.OutputFormat, nil)
+	})
+}
+
+func runResume(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("resume", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", "", "output folder of the interrupted run (required)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if *output == "" || fs.NArg() > 0 {
+		fmt.Fprintln(stderr, "error: resume takes only --output")
+		return exitUsage
+	}
+
+	journal, err := processor.LoadJournal(*output)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailure
+	}
+	proc, err := processor.NewResumeProcessor(journal, &fileio.Handler{})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailure
+	}
+	if n := len(journal.Config.Recipients); n > 0 {
+		fmt.Fprintf(stdout, "Resuming export of %s for %d recipients started %s, %d already done\n",
+			journal.Input, n, journal.Started.Format(time.DateTime), len(journal.DoneRecipients))
+	} else {
+		fmt.Fprintf(stdout, "Resuming run of %s started %s, %d files already done\n",
+			journal.Input, journal.Started.Format(time.DateTime), len(journal.Done))
+	}
+	return runBatch(stdout, stderr, proc.OutputDirFor(journal.Input), func(ctx context.Context) (*processor.BatchResult, error) {
+		return proc.Resume(ctx, journal, nil)
+	})
+}
+
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: goimgtool-cli verify <file>...")
+		fmt.Fprintln(stderr, "Prints the owner and image ID of the invisible watermark in each file.")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "error: verify needs at least one image file")
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, path := range fs.Args() {
+		img, err := fileio.LoadImage(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			code = exitFailure
+			continue
+		}
+		mark, err := processor.ExtractForensic(img)
+		if err != nil {
+			fmt.Fprintf(stdout, "%s: %v\n", path, err)
+			code = exitFailure
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: %v\n", path, mark)
+	}
+	return code
+}
+
+// runBatch runs a batch until it finishes or the process is interrupted,
+// prints its result and maps it to an exit code.
+func runBatch(stdout, stderr io.Writer, outputDir string, process func(ctx context.Context) (*processor.BatchResult, error)) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := process(ctx)
+	if result != nil {
+		printResult(stdout, result)
+	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(stderr, "%v\nRun \"goimgtool-cli resume --output %s\" to continue.\n", err, outputDir)
+		return exitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "processing failed: %v\n", err)
+		return exitFailure
+	}
+	if result.Count(processor.StatusFailed) > 0 {
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "Output written to %s\n", outputDir)
+	return exitOK
+}
+
+func printResult(w io.Writer, result *processor.BatchResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tINPUT\tOUTPUT\tSIZE\tQUALITY\tTIME\tDETAIL")
+	for _, f := range result.Files {
+		detail := f.Reason
+		if f.Err != nil {
+			detail = f.Err.Error()
+		}
+		if f.CollidedWith != "" && f.Err == nil {
+			detail = strings.TrimPrefix(detail+"; collided with "+f.CollidedWith, "; ")
+		}
+		if f.ImageID != "" {
+			detail = strings.TrimPrefix(detail+"; image ID "+f.ImageID, "; ")
+		}
+		if len(f.Anchors) > 0 {
+			detail = strings.TrimPrefix(detail+"; anchor "+strings.Join(f.Anchors, ", "), "; ")
+		}
+		size, quality := "-", "-"
+		if f.Size > 0 {
+			size = fmt.Sprintf("%d KB", f.Size/1024)
+			quality = strconv.Itoa(f.Quality)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%v\t%s\n", f.Status, f.InputPath, f.OutputPath, size, quality, f.Duration.Round(time.Millisecond), detail)
+		// Further renditions of the same source go on rows of their own.
+		for i := 1; i < len(f.Renditions); i++ {
+			r := f.Renditions[i]
+			fmt.Fprintf(tw, "\t\t%s\t%d KB\t%d\t\t\n", r.Path, r.Size/1024, r.Quality)
+		}
+	}
+	tw.Flush()
+	for _, path := range result.Pruned {
+		fmt.Fprintf(w, "pruned %s\n", path)
+	}
+	fmt.Fprintln(w, result)
+}
+
+func parseProcessFlags(args []string, output io.Writer) (*processOptions, error) {
+	opts := &processOptions{}
+	var maxSize, margin, tileOffset string
+
+	fs := flag.NewFlagSet("process", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.input, "input", "", "folder with the source images (required)")
+	fs.StringVar(&opts.watermark, "watermark", "", "watermark image file: png, jpg, webp or svg (required unless --text is set)")
+	fs.StringVar(&opts.portrait, "watermark-portrait", "", "watermark file for portrait images (default: --watermark)")
+	fs.StringVar(&opts.landscape, "watermark-landscape", "", "watermark file for landscape images (default: --watermark)")
+	fs.StringVar(&opts.square, "watermark-square", "", "watermark file for square images (default: --watermark)")
+	fs.Var(&opts.layers, "layer", layerUsage)
+	fs.StringVar(&opts.text, "text", "", "draw this text as the watermark instead of an image")
+	fs.StringVar(&opts.font, "font", "", "with --text, TTF or OTF font file (default: built-in Go font)")
+	fs.Float64Var(&opts.textSize, "text-size", 5, "with --text, font size as percent of the image's shorter side")
+	fs.StringVar(&opts.textColor, "text-color", "#FFFFFF", "with --text, color as #RRGGBB or #RRGGBBAA")
+	fs.StringVar(&opts.textEffect, "text-effect", config.TextEffectShadow, "with --text, none, outline or shadow")
+	fs.StringVar(&opts.recipients, "recipients", "", "CSV file with id and name columns: export one copy per recipient into <output>/<id>, with {recipient} or {recipient_id} in --text, --layer texts and --forensic replaced (or the name added as a text layer)")
+	fs.StringVar(&opts.forensic, "forensic", "", fmt.Sprintf("embed an invisible watermark with this owner ID (up to %d bytes) and a per-image ID; read it back with verify", processor.ForensicOwnerMax))
+	fs.StringVar(&opts.format, "format", "jpg", "output format: jpg, png or webp")
+	fs.StringVar(&maxSize, "max", "1200x1200", "maximum output size as WIDTHxHEIGHT, the exact size with --resize fill")
+	fs.Func("renditions", "also write every image at these sizes, named with a size suffix: comma-separated SIZE[:KB[:MAXQUALITY]], SIZE being WIDTH, WIDTHxHEIGHT or xHEIGHT, e.g. \"320:30,640:60,1024,1920:250:90\"", func(s string) (err error) {
+		opts.renditions, err = config.ParseRenditions(s)
+		return err
+	})
+	fs.StringVar(&opts.resize, "resize", config.ResizeFit, "fit: scale down to fit within --max; fill: scale and crop to exactly --max; pad: scale to fit and pad to exactly --max")
+	fs.StringVar(&opts.pad, "pad", "#FFFFFF", "with --resize pad, padding color as #RRGGBB or #RRGGBBAA, or "+config.PadBlur+" for a blurred copy of the image")
+	fs.StringVar(&opts.crop, "crop", config.CropCenter, "with --resize fill, the part of the image to keep: "+strings.Join(config.CropAnchors, ", "))
+	fs.IntVar(&opts.maxQuality, "max-quality", 0, "highest jpg/webp quality the size optimizer may pick (1-100), 0 for no limit")
+	fs.IntVar(&opts.targetSizeKB, "target-kb", 100, "maximum output file size in KB")
+	fs.StringVar(&opts.mode, "mode", "crop", "watermark mode: crop, resize, anchor or tile")
+	fs.IntVar(&opts.opacity, "opacity", 100, "watermark opacity in percent (0-100)")
+	fs.StringVar(&opts.blendMode, "blend", config.BlendNormal, "how the watermark mixes with the image: "+strings.Join(config.BlendModes, ", "))
+	fs.BoolVar(&opts.adaptive, "adaptive", false, "per image, use whichever of the watermark and its variant contrasts more with the image under it")
+	fs.StringVar(&opts.variant, "variant", "", "with --adaptive, second watermark file (default: the watermark with inverted colors)")
+	fs.StringVar(&opts.anchor, "anchor", config.AnchorBottomRight, "with --mode anchor, where to place the watermark: "+strings.Join(config.Anchors, ", "))
+	fs.StringVar(&margin, "margin", "3%", "with --mode anchor, gap to the image edges in pixels (16) or percent of the shorter side (3%)")
+	fs.Float64Var(&opts.scale, "scale", 20, "with --mode anchor or tile, watermark size as percent of the image's shorter side (0 = original size)")
+	fs.IntVar(&opts.tileSpacing, "tile-spacing", 50, "with --mode tile, pixels between tiles")
+	fs.StringVar(&tileOffset, "tile-offset", "0,0", "with --mode tile, shift of the grid as X,Y pixels")
+	fs.BoolVar(&opts.tileRotate, "tile-rotate", false, "with --mode tile, turn the pattern by 45 degrees")
+	fs.IntVar(&opts.workers, "workers", runtime.NumCPU(), "number of files processed in parallel")
+	fs.IntVar(&opts.memBudgetMB, "mem-budget-mb", 0, "lower the worker count to fit decoded images in this many MB (0 = no limit)")
+	fs.StringVar(&opts.output, "output", processor.DefaultOutputDir, "output folder, absolute or relative to --input")
+	fs.StringVar(&opts.nameTemplate, "name", processor.DefaultNameTemplate, "output file name template; tokens: {name} {ext} {width} {height} {quality} {index} {date}")
+	fs.StringVar(&opts.collision, "on-collision", config.CollisionOverwrite, "when an output file exists: overwrite, skip, suffix or fail")
+	fs.BoolVar(&opts.incremental, "incremental", false, "skip sources unchanged since the last run, using a manifest in the output folder")
+	fs.BoolVar(&opts.prune, "prune", false, "with --incremental, delete outputs whose source file was removed")
+	fs.BoolVar(&opts.recursive, "recursive", false, "also process subfolders, mirroring them in the output")
+	fs.IntVar(&opts.maxDepth, "max-depth", 0, "with --recursive, how many folder levels to descend (0 = no limit)")
+	fs.BoolVar(&opts.hidden, "include-hidden", false, "with --recursive, also descend into hidden (dot) folders")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if opts.input == "" {
+		return nil, fmt.Errorf("--input is required")
+	}
+	switch {
+	case opts.watermark == "" && opts.text == "":
+		return nil, fmt.Errorf("--watermark or --text is required")
+	case opts.watermark != "" && opts.text != "":
+		return nil, fmt.Errorf("--watermark and --text can't be used together")
+	}
+	if opts.text != "" {
+		modeSet := false
+		fs.Visit(func(f *flag.Flag) { modeSet = modeSet || f.Name == "mode" })
+		if !modeSet {
+			opts.mode = "anchor"
+		} else if opts.mode != "anchor" && opts.mode != "tile" {
+			return nil, fmt.Errorf("--text needs --mode anchor or tile")
+		}
+		if opts.textSize <= 0 || opts.textSize > 100 {
+			return nil, fmt.Errorf("--text-size must be between 0 and 100")
+		}
+		if _, err := config.ParseColor(opts.textColor); err != nil {
+			return nil, err
+		}
+		switch opts.textEffect {
+		case config.TextEffectNone, config.TextEffectOutline, config.TextEffectShadow:
+		default:
+			return nil, fmt.Errorf("unsupported text effect: %s", opts.textEffect)
+		}
+	}
+	opts.format = strings.ToLower(opts.format)
+	switch opts.format {
+	case "jpg", "jpeg", "png", "webp":
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", opts.format)
+	}
+	switch opts.mode {
+	case "crop", "resize", "anchor", "tile":
+	default:
+		return nil, fmt.Errorf("unsupported watermark mode: %s", opts.mode)
+	}
+	if !slices.Contains(config.ResizeModes, opts.resize) {
+		return nil, fmt.Errorf("unsupported resize mode: %s", opts.resize)
+	}
+	if opts.pad != config.PadBlur {
+		if _, err := config.ParseColor(opts.pad); err != nil {
+			return nil, fmt.Errorf("--pad: %v", err)
+		}
+	}
+	if !slices.Contains(config.CropAnchors, opts.crop) {
+		return nil, fmt.Errorf("unsupported crop anchor: %s", opts.crop)
+	}
+	switch opts.collision {
+	case config.CollisionOverwrite, config.CollisionSkip, config.CollisionSuffix, config.CollisionFail:
+	default:
+		return nil, fmt.Errorf("unsupported collision policy: %s", opts.collision)
+	}
+	if opts.targetSizeKB < 1 {
+		return nil, fmt.Errorf("--target-kb must be positive")
+	}
+	if opts.maxQuality < 0 || opts.maxQuality > 100 {
+		return nil, fmt.Errorf("--max-quality must be 0 for no limit, otherwise 1-100")
+	}
+	if opts.workers < 1 {
+		return nil, fmt.Errorf("--workers must be positive")
+	}
+	if opts.memBudgetMB < 0 {
+		return nil, fmt.Errorf("--mem-budget-mb must not be negative")
+	}
+	if opts.prune && !opts.incremental {
+		return nil, fmt.Errorf("--prune requires --incremental")
+	}
+	if opts.maxDepth < 0 {
+		return nil, fmt.Errorf("--max-depth must not be negative")
+	}
+
+	width, height, err := parseSize(maxSize)
+	if err != nil {
+		return nil, err
+	}
+	opts.maxWidth, opts.maxHeight = width, height
+	if opts.opacity < 0 || opts.opacity > 100 {
+		return nil, fmt.Errorf("--opacity must be between 0 and 100")
+	}
+	if !slices.Contains(config.BlendModes, opts.blendMode) {
+		return nil, fmt.Errorf("unsupported blend mode: %s", opts.blendMode)
+	}
+	if opts.variant != "" && !opts.adaptive {
+		return nil, fmt.Errorf("--variant requires --adaptive")
+	}
+	// With recipient tokens the length is only known per recipient.
+	perRecipient := strings.Contains(opts.forensic, config.RecipientToken) || strings.Contains(opts.forensic, config.RecipientIDToken)
+	if !perRecipient && len(opts.forensic) > processor.ForensicOwnerMax {
+		return nil, fmt.Errorf("--forensic must be at most %d bytes", processor.ForensicOwnerMax)
+	}
+	if !slices.Contains(config.Anchors, opts.anchor) {
+		return nil, fmt.Errorf("unsupported anchor: %s", opts.anchor)
+	}
+	if opts.margin, opts.marginUnit, err = config.ParseMargin(margin); err != nil {
+		return nil, err
+	}
+	if opts.scale < 0 || opts.scale > 100 {
+		return nil, fmt.Errorf("--scale must be between 0 and 100")
+	}
+	if opts.tileSpacing < 0 {
+		return nil, fmt.Errorf("--tile-spacing must not be negative")
+	}
+	if opts.tileOffsetX, opts.tileOffsetY, err = parseOffset(tileOffset); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+func parseOffset(s string) (int, int, error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid offset %q, expected X,Y", s)
+	}
+	x, err := strconv.Atoi(strings.TrimSpace(xs))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid X in offset %q", s)
+	}
+	y, err := strconv.Atoi(strings.TrimSpace(ys))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Y in offset %q", s)
+	}
+	return x, y, nil
+}
+
+func parseSize(s string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid size %q, expected WIDTHxHEIGHT", s)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil || width < 1 {
+		return 0, 0, fmt.Errorf("invalid width in %q", s)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height < 1 {
+		return 0, 0, fmt.Errorf("invalid height in %q", s)
+	}
+	return width, height, nil
+}
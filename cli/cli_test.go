@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/del1x/GoIMGtool/processor"
+	"github.com/disintegration/imaging"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		w, h    int
+		wantErr bool
+	}{
+		{in: "1200x1200", w: 1200, h: 1200},
+		{in: "1080X1350", w: 1080, h: 1350},
+		{in: "1200", wantErr: true},
+		{in: "0x100", wantErr: true},
+		{in: "axb", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			w, h, err := parseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && (w != tt.w || h != tt.h) {
+				t.Errorf("parseSize(%q) = %dx%d, want %dx%d", tt.in, w, h, tt.w, tt.h)
+			}
+		})
+	}
+}
+
+func TestParseProcessFlags(t *testing.T) {
+	opts, err := parseProcessFlags([]string{
+		"--input", "photos", "--watermark", "wm.png", "--format", "WEBP",
+		"--max", "800x600", "--target-kb", "150", "--max-quality", "85", "--mode", "resize", "--resize", "fill", "--crop", "auto",
+		"--renditions", "640:60, 1920:250:90",
+	}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseProcessFlags() error = %v", err)
+	}
+	if opts.input != "photos" || opts.watermark != "wm.png" || opts.format != "webp" ||
+		opts.maxWidth != 800 || opts.maxHeight != 600 || opts.targetSizeKB != 150 || opts.maxQuality != 85 || opts.mode != "resize" ||
+		opts.resize != "fill" || opts.crop != "auto" || len(opts.renditions) != 2 || opts.renditions[1].MaxQuality != 90 {
+		t.Errorf("parseProcessFlags() = %+v", opts)
+	}
+}
+
+func TestParseProcessFlagsAnchor(t *testing.T) {
+	opts, err := parseProcessFlags([]string{
+		"--input", "photos", "--watermark", "wm.png", "--mode", "anchor",
+		"--anchor", "top-left", "--margin", "24", "--scale", "15", "--opacity", "40", "--blend", "multiply",
+	}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseProcessFlags() error = %v", err)
+	}
+	if opts.mode != "anchor" || opts.anchor != "top-left" || opts.margin != 24 || opts.marginUnit != "px" || opts.scale != 15 || opts.opacity != 40 || opts.blendMode != "multiply" {
+		t.Errorf("parseProcessFlags() = %+v", opts)
+	}
+}
+
+func TestParseProcessFlagsTile(t *testing.T) {
+	opts, err := parseProcessFlags([]string{
+		"--input", "photos", "--watermark", "wm.png", "--mode", "tile",
+		"--tile-spacing", "30", "--tile-offset", "-10, 25", "--tile-rotate",
+	}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseProcessFlags() error = %v", err)
+	}
+	if opts.mode != "tile" || opts.tileSpacing != 30 || opts.tileOffsetX != -10 || opts.tileOffsetY != 25 || !opts.tileRotate {
+		t.Errorf("parseProcessFlags() = %+v", opts)
+	}
+}
+
+func TestParseProcessFlagsText(t *testing.T) {
+	opts, err := parseProcessFlags([]string{
+		"--input", "photos", "--text", "© Brand", "--text-color", "#FF000080", "--text-effect", "outline",
+	}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseProcessFlags() error = %v", err)
+	}
+	if opts.text != "© Brand" || opts.mode != "anchor" || opts.textColor != "#FF000080" || opts.textEffect != "outline" {
+		t.Errorf("parseProcessFlags() = %+v", opts)
+	}
+}
+
+func TestParseProcessFlagsLayers(t *testing.T) {
+	opts, err := parseProcessFlags([]string{
+		"--input", "photos", "--watermark", "pattern.png", "--mode", "tile",
+		"--layer", "file=logo.svg,anchor=top-right,scale=15,margin=2%",
+		"--layer", "text=© Brand, Inc.,anchor=bottom-left,opacity=70,blend=screen",
+	}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseProcessFlags() error = %v", err)
+	}
+	if len(opts.layers) != 2 {
+		t.Fatalf("parsed %d layers, want 2", len(opts.layers))
+	}
+	logo, text := opts.layers[0], opts.layers[1]
+	if logo.Watermark != "logo.svg" || logo.Mode != "anchor" || logo.Anchor != "top-right" || logo.Scale != 15 || logo.Margin != 2 || logo.MarginUnit != "%" {
+		t.Errorf("first layer = %+v", logo)
+	}
+	if text.Text != "© Brand, Inc." || text.Anchor != "bottom-left" || text.Opacity != 70 || text.BlendMode != "screen" {
+		t.Errorf("second layer = %+v", text)
+	}
+}
+
+func TestParseProcessFlagsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "missing input", args: []string{"--watermark", "wm.png"}},
+		{name: "missing watermark", args: []string{"--input", "photos"}},
+		{name: "bad format", args: []string{"--input", "photos", "--watermark", "wm.png", "--format", "gif"}},
+		{name: "bad mode", args: []string{"--input", "photos", "--watermark", "wm.png", "--mode", "stretch"}},
+		{name: "bad opacity", args: []string{"--input", "photos", "--watermark", "wm.png", "--opacity", "101"}},
+		{name: "bad blend mode", args: []string{"--input", "photos", "--watermark", "wm.png", "--blend", "burn"}},
+		{name: "variant without adaptive", args: []string{"--input", "photos", "--watermark", "wm.png", "--variant", "dark.png"}},
+		{name: "bad anchor", args: []string{"--input", "photos", "--watermark", "wm.png", "--anchor", "middle"}},
+		{name: "bad margin", args: []string{"--input", "photos", "--watermark", "wm.png", "--margin", "-2%"}},
+		{name: "bad scale", args: []string{"--input", "photos", "--watermark", "wm.png", "--scale", "150"}},
+		{name: "bad tile offset", args: []string{"--input", "photos", "--watermark", "wm.png", "--tile-offset", "10"}},
+		{name: "watermark and text", args: []string{"--input", "photos", "--watermark", "wm.png", "--text", "x"}},
+		{name: "text in crop mode", args: []string{"--input", "photos", "--text", "x", "--mode", "crop"}},
+		{name: "bad text color", args: []string{"--input", "photos", "--text", "x", "--text-color", "red"}},
+		{name: "layer without source", args: []string{"--input", "photos", "--watermark", "wm.png", "--layer", "anchor=top"}},
+		{name: "layer with unknown key", args: []string{"--input", "photos", "--watermark", "wm.png", "--layer", "file=a.png,size2=3"}},
+		{name: "long forensic owner", args: []string{"--input", "photos", "--watermark", "wm.png", "--forensic", "an owner ID longer than the payload"}},
+		{name: "bad resize mode", args: []string{"--input", "photos", "--watermark", "wm.png", "--resize", "stretch"}},
+		{name: "bad crop anchor", args: []string{"--input", "photos", "--watermark", "wm.png", "--resize", "fill", "--crop", "left"}},
+		{name: "bad pad background", args: []string{"--input", "photos", "--watermark", "wm.png", "--resize", "pad", "--pad", "gray"}},
+		{name: "bad renditions", args: []string{"--input", "photos", "--watermark", "wm.png", "--renditions", "320,wide"}},
+		{name: "extra args", args: []string{"--input", "photos", "--watermark", "wm.png", "extra"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseProcessFlags(tt.args, io.Discard); err == nil {
+				t.Errorf("parseProcessFlags(%v) expected error", tt.args)
+			}
+		})
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	if code := Run(nil, io.Discard, io.Discard); code != exitUsage {
+		t.Errorf("Run(nil) = %d, want %d", code, exitUsage)
+	}
+	if code := Run([]string{"bogus"}, io.Discard, io.Discard); code != exitUsage {
+		t.Errorf("Run(bogus) = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	if code := Run([]string{"verify"}, io.Discard, io.Discard); code != exitUsage {
+		t.Errorf("Run(verify) = %d, want %d", code, exitUsage)
+	}
+
+	path := filepath.Join(t.TempDir(), "plain.png")
+	if err := imaging.Save(image.NewNRGBA(image.Rect(0, 0, 200, 200)), path); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if code := Run([]string{"verify", path}, &out, io.Discard); code != exitFailure {
+		t.Errorf("Run(verify unmarked) = %d, want %d", code, exitFailure)
+	}
+	if !strings.Contains(out.String(), processor.ErrNoForensicMark.Error()) {
+		t.Errorf("verify output = %q", out.String())
+	}
+}
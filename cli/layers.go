@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/del1x/GoIMGtool/config"
+)
+
+const layerUsage = `extra watermark drawn over the main one, repeatable, in drawing order.
+Comma-separated key=value pairs: file or text, and optionally mode, anchor,
+margin, scale, opacity, blend, spacing, offset-x, offset-y, rotate, font,
+size, color, effect. Example: "text=© Brand 2026,anchor=bottom-left,opacity=70"`
+
+// layerFlag collects repeated --layer flags.
+type layerFlag []config.Layer
+
+func (f *layerFlag) String() string {
+	return fmt.Sprintf("%d layers", len(*f))
+}
+
+func (f *layerFlag) Set(spec string) error {
+	layer, err := parseLayer(spec)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, layer)
+	return nil
+}
+
+// parseLayer reads a --layer spec. A part without "=" continues the value
+// before it, so texts may contain commas.
+func parseLayer(spec string) (config.Layer, error) {
+	layer := config.NewLayer("", "anchor")
+	var keys []string
+	values := make(map[string]string)
+	for _, part := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			if len(keys) == 0 {
+				return layer, fmt.Errorf("invalid layer %q, expected key=value pairs", spec)
+			}
+			values[keys[len(keys)-1]] += "," + part
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if _, dup := values[key]; dup {
+			return layer, fmt.Errorf("layer key %q given twice", key)
+		}
+		keys = append(keys, key)
+		values[key] = value
+	}
+
+	var err error
+	for _, key := range keys {
+		value := values[key]
+		switch key {
+		case "file":
+			layer.Watermark = value
+		case "text":
+			layer.Text = value
+		case "font":
+			layer.FontPath = value
+		case "mode":
+			layer.Mode = value
+			if !slices.Contains([]string{"crop", "resize", "anchor", "tile"}, value) {
+				err = fmt.Errorf("unsupported watermark mode: %s", value)
+			}
+		case "anchor":
+			layer.Anchor = value
+			if !slices.Contains(config.Anchors, value) {
+				err = fmt.Errorf("unsupported anchor: %s", value)
+			}
+		case "margin":
+			layer.Margin, layer.MarginUnit, err = config.ParseMargin(value)
+		case "scale":
+			layer.Scale, err = strconv.ParseFloat(value, 64)
+		case "opacity":
+			layer.Opacity, err = strconv.Atoi(value)
+		case "blend":
+			layer.BlendMode = value
+			if !slices.Contains(config.BlendModes, value) {
+				err = fmt.Errorf("unsupported blend mode: %s", value)
+			}
+		case "spacing":
+			layer.TileSpacing, err = strconv.Atoi(value)
+		case "offset-x":
+			layer.TileOffsetX, err = strconv.Atoi(value)
+		case "offset-y":
+			layer.TileOffsetY, err = strconv.Atoi(value)
+		case "rotate":
+			layer.TileRotate, err = strconv.ParseBool(value)
+		case "size":
+			layer.TextSize, err = strconv.ParseFloat(value, 64)
+		case "color":
+			_, err = config.ParseColor(value)
+			layer.TextColor = value
+		case "effect":
+			layer.TextEffect = value
+		default:
+			err = fmt.Errorf("unknown layer key %q", key)
+		}
+		if err != nil {
+			return layer, fmt.Errorf("layer %s: %v", key, err)
+		}
+	}
+	if (layer.Watermark == "") == (layer.Text == "") {
+		return layer, fmt.Errorf("layer needs either file or text")
+	}
+	return layer, nil
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/del1x/GoIMGtool/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -1,14 +1,193 @@
 package config
 
 import (
+	"fmt"
+	"image/color"
+	"runtime"
+	"strconv"
 	"strings"
 )
 
+// Collision policies for output files that already exist.
+const (
+	CollisionOverwrite = "overwrite"
+	CollisionSkip      = "skip"
+	CollisionSuffix    = "suffix"
+	CollisionFail      = "fail"
+)
+
+// Anchor positions for the "anchor" watermark mode.
+const (
+	AnchorTopLeft     = "top-left"
+	AnchorTop         = "top"
+	AnchorTopRight    = "top-right"
+	AnchorLeft        = "left"
+	AnchorCenter      = "center"
+	AnchorRight       = "right"
+	AnchorBottomLeft  = "bottom-left"
+	AnchorBottom      = "bottom"
+	AnchorBottomRight = "bottom-right"
+	// AnchorAuto picks, per image, the edge or corner position where the
+	// image has the least detail.
+	AnchorAuto = "auto"
+)
+
+// Anchors lists the anchor positions row by row, top-left first, followed
+// by AnchorAuto.
+var Anchors = []string{
+	AnchorTopLeft, AnchorTop, AnchorTopRight,
+	AnchorLeft, AnchorCenter, AnchorRight,
+	AnchorBottomLeft, AnchorBottom, AnchorBottomRight,
+	AnchorAuto,
+}
+
+// Resize modes: ResizeFit scales images down to fit within MaxWidth x
+// MaxHeight, ResizeFill scales and crops them to exactly that size and
+// ResizePad scales them to fit and pads them to exactly that size.
+const (
+	ResizeFit  = "fit"
+	ResizeFill = "fill"
+	ResizePad  = "pad"
+)
+
+// ResizeModes lists the resize modes, the default first.
+var ResizeModes = []string{ResizeFit, ResizeFill, ResizePad}
+
+// PadBlur as Config.PadBackground fills the padding with a blurred,
+// enlarged copy of the image.
+const PadBlur = "blur"
+
+// Crop anchors for ResizeFill: which part of the image is kept.
+const (
+	CropCenter = "center"
+	CropTop    = "top"
+	CropAuto   = "auto" // the part with the most detail, by luminance entropy
+)
+
+// CropAnchors lists the crop anchors, the default first.
+var CropAnchors = []string{CropCenter, CropTop, CropAuto}
+
+// Units for Config.Margin.
+const (
+	MarginPixels  = "px"
+	MarginPercent = "%"
+)
+
+// Effects drawn behind text watermarks.
+const (
+	TextEffectNone    = "none"
+	TextEffectOutline = "outline"
+	TextEffectShadow  = "shadow"
+)
+
+// Blend modes for compositing the watermark onto the image.
+const (
+	BlendNormal     = "normal"
+	BlendMultiply   = "multiply"
+	BlendScreen     = "screen"
+	BlendOverlay    = "overlay"
+	BlendSoftLight  = "soft-light"
+	BlendDifference = "difference"
+)
+
+// BlendModes lists the supported blend modes, BlendNormal first.
+var BlendModes = []string{BlendNormal, BlendMultiply, BlendScreen, BlendOverlay, BlendSoftLight, BlendDifference}
+
 type Config struct {
 	MaxWidth     int
 	MaxHeight    int
 	OutputFormat string
-	Quality      int // for JPEG/WebP (1-100)
+	Quality      int    // for JPEG/WebP (1-100)
+	MaxQuality   int    // highest quality the size optimizer may pick, 0 for no limit
+	MinQuality   int    // lowest quality the size optimizer may pick, 0 for no limit
+	TargetSizeKB int    // upper bound for the encoded file size
+	ResizeMode   string // one of the Resize* modes
+	CropAnchor   string // with ResizeFill, one of the Crop* anchors
+	// PadBackground fills the padding of ResizePad: a #RRGGBB or #RRGGBBAA
+	// color, or PadBlur.
+	PadBackground string
+	Workers       int // files processed in parallel
+	// MemoryBudgetMB caps Workers so that the estimated decoded pixel
+	// buffers of concurrent files fit in the budget. Zero disables the cap.
+	MemoryBudgetMB int
+	Recursive      bool // walk subdirectories and mirror them in the output
+	MaxDepth       int  // directory levels below the input folder to walk, 0 for no limit
+	SkipHidden     bool // don't descend into dot-prefixed folders
+	// OutputDir is where results are written. A relative path is resolved
+	// against the input folder.
+	OutputDir string
+	// NameTemplate builds output file names from the tokens {name}, {ext},
+	// {width}, {height}, {quality}, {index} and {date}.
+	NameTemplate string
+	Collision    string // one of the Collision* policies
+	// Incremental skips sources whose content and settings match the
+	// manifest kept in the output folder.
+	Incremental  bool
+	PruneOrphans bool // with Incremental, delete outputs whose source is gone
+	// WatermarkSettings describe how the main watermark is drawn.
+	WatermarkSettings
+	// Adaptive picks, for each image, whichever of the watermark and its
+	// variant contrasts more with the part of the image under it. The
+	// variant is the image at AdaptiveVariant, or the watermark with
+	// inverted colors when that is empty.
+	Adaptive        bool
+	AdaptiveVariant string
+	// PortraitWatermark, LandscapeWatermark and SquareWatermark replace the
+	// watermark for images of that orientation. Empty ones fall back to it.
+	PortraitWatermark  string
+	LandscapeWatermark string
+	SquareWatermark    string
+	// Layers are further watermarks, composited in order over the main one.
+	Layers []Layer
+	// ForensicOwner, when set, is embedded together with a per-image ID as
+	// an invisible watermark that survives re-encoding.
+	ForensicOwner string
+	// Renditions, when set, make every source come out in each of these
+	// sizes, decoded and watermarked once at the largest of them. Output
+	// names get the rendition's Suffix.
+	Renditions []Rendition
+	// Recipients, when set, make a run export one copy of the output per
+	// recipient, in subfolders named by their IDs; see ForRecipient.
+	Recipients []Recipient
+}
+
+// WatermarkSettings describe how one watermark is drawn.
+type WatermarkSettings struct {
+	Opacity   int    // watermark opacity in percent, applied on top of its own alpha
+	BlendMode string // one of the Blend* modes
+	// Anchor, Margin and Scale place the watermark in the "anchor" mode.
+	// Margin is the gap to the anchored edges, either in pixels or as a
+	// percentage of the image's shorter side. Scale sizes the watermark's
+	// longer side as a percentage of the image's shorter side, keeping its
+	// aspect ratio; zero keeps the watermark's own size.
+	Anchor     string
+	Margin     float64
+	MarginUnit string // MarginPixels or MarginPercent
+	Scale      float64
+	// The "tile" mode repeats the watermark, sized by Scale, in a grid with
+	// TileSpacing pixels between tiles. TileOffsetX and TileOffsetY shift the
+	// grid and TileRotate turns the whole pattern by 45 degrees.
+	TileSpacing int
+	TileOffsetX int
+	TileOffsetY int
+	TileRotate  bool
+	// A non-empty Text is drawn in place of a watermark image, using the
+	// TTF/OTF font at FontPath or the built-in Go font when it is empty.
+	// TextSize is the font size as a percentage of the image's shorter side,
+	// so the text is rendered at each output's own resolution.
+	Text       string
+	FontPath   string
+	TextSize   float64
+	TextColor  string // #RRGGBB or #RRGGBBAA
+	TextEffect string // one of the TextEffect* styles
+}
+
+// Layer is a watermark drawn on top of the main one, with its own source,
+// mode and settings.
+type Layer struct {
+	Watermark string // image or SVG file; leave empty to draw Text instead
+	Mode      string // crop, resize, anchor or tile
+	WatermarkSettings
 }
 
 func NewConfig(width, height int, format string, quality int) *Config {
@@ -22,13 +201,46 @@ func NewConfig(width, height int, format string, quality int) *Config {
 		quality = 100
 	}
 	return &Config{
-		MaxWidth:     width,
-		MaxHeight:    height,
-		OutputFormat: normFormat,
-		Quality:      quality,
+		MaxWidth:          width,
+		MaxHeight:         height,
+		OutputFormat:      normFormat,
+		Quality:           quality,
+		TargetSizeKB:      100,
+		ResizeMode:        ResizeFit,
+		CropAnchor:        CropCenter,
+		PadBackground:     "#FFFFFF",
+		Workers:           runtime.NumCPU(),
+		SkipHidden:        true,
+		OutputDir:         "Images_watermarked",
+		NameTemplate:      "{name}.{ext}",
+		Collision:         CollisionOverwrite,
+		WatermarkSettings: DefaultWatermarkSettings(),
+	}
+}
+
+// DefaultWatermarkSettings returns the settings a new Config or Layer
+// starts from.
+func DefaultWatermarkSettings() WatermarkSettings {
+	return WatermarkSettings{
+		Opacity:     100,
+		BlendMode:   BlendNormal,
+		Anchor:      AnchorBottomRight,
+		Margin:      3,
+		MarginUnit:  MarginPercent,
+		Scale:       20,
+		TileSpacing: 50,
+		TextSize:    5,
+		TextColor:   "#FFFFFF",
+		TextEffect:  TextEffectShadow,
 	}
 }
 
+// NewLayer returns a layer drawing the watermark file in the given mode
+// with the default settings.
+func NewLayer(watermark, mode string) Layer {
+	return Layer{Watermark: watermark, Mode: mode, WatermarkSettings: DefaultWatermarkSettings()}
+}
+
 func DefaultConfig() *Config {
 	return NewConfig(1200, 1200, "png", 75)
 }
@@ -46,3 +258,182 @@ func (c *Config) WithMaxSize(width, height int) *Config {
 	c.MaxHeight = height
 	return c
 }
+
+// WithFill makes outputs exactly MaxWidth x MaxHeight, cropping at the
+// given anchor.
+func (c *Config) WithFill(anchor string) *Config {
+	c.ResizeMode = ResizeFill
+	c.CropAnchor = anchor
+	return c
+}
+
+// WithPad makes outputs exactly MaxWidth x MaxHeight, fitting the image
+// inside and filling the rest with background.
+func (c *Config) WithPad(background string) *Config {
+	c.ResizeMode = ResizePad
+	c.PadBackground = background
+	return c
+}
+
+func (c *Config) WithTargetSize(sizeKB int) *Config {
+	c.TargetSizeKB = sizeKB
+	return c
+}
+
+func (c *Config) WithMaxQuality(quality int) *Config {
+	c.MaxQuality = quality
+	return c
+}
+
+func (c *Config) WithWorkers(workers int) *Config {
+	if workers < 1 {
+		workers = 1
+	}
+	c.Workers = workers
+	return c
+}
+
+func (c *Config) WithMemoryBudget(budgetMB int) *Config {
+	if budgetMB < 0 {
+		budgetMB = 0
+	}
+	c.MemoryBudgetMB = budgetMB
+	return c
+}
+
+func (c *Config) WithRecursive(maxDepth int) *Config {
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
+	c.Recursive = true
+	c.MaxDepth = maxDepth
+	return c
+}
+
+func (c *Config) WithOutput(dir, nameTemplate string) *Config {
+	c.OutputDir = dir
+	c.NameTemplate = nameTemplate
+	return c
+}
+
+func (c *Config) WithCollision(policy string) *Config {
+	c.Collision = policy
+	return c
+}
+
+func (c *Config) WithIncremental(prune bool) *Config {
+	c.Incremental = true
+	c.PruneOrphans = prune
+	return c
+}
+
+func (c *Config) WithOpacity(percent int) *Config {
+	c.Opacity = min(max(percent, 0), 100)
+	return c
+}
+
+func (c *Config) WithBlendMode(mode string) *Config {
+	c.BlendMode = mode
+	return c
+}
+
+func (c *Config) WithAdaptive(variantPath string) *Config {
+	c.Adaptive = true
+	c.AdaptiveVariant = variantPath
+	return c
+}
+
+func (c *Config) WithOrientationWatermarks(portrait, landscape, square string) *Config {
+	c.PortraitWatermark = portrait
+	c.LandscapeWatermark = landscape
+	c.SquareWatermark = square
+	return c
+}
+
+func (c *Config) WithLayers(layers ...Layer) *Config {
+	c.Layers = layers
+	return c
+}
+
+func (c *Config) WithForensic(owner string) *Config {
+	c.ForensicOwner = owner
+	return c
+}
+
+func (c *Config) WithRenditions(renditions ...Rendition) *Config {
+	c.Renditions = renditions
+	return c
+}
+
+func (c *Config) WithRecipients(recipients ...Recipient) *Config {
+	c.Recipients = recipients
+	return c
+}
+
+func (c *Config) WithAnchor(anchor string, margin float64, unit string, scale float64) *Config {
+	c.Anchor = anchor
+	c.Margin = margin
+	c.MarginUnit = unit
+	c.Scale = scale
+	return c
+}
+
+func (c *Config) WithTile(spacing, offsetX, offsetY int, rotate bool) *Config {
+	if spacing < 0 {
+		spacing = 0
+	}
+	c.TileSpacing = spacing
+	c.TileOffsetX = offsetX
+	c.TileOffsetY = offsetY
+	c.TileRotate = rotate
+	return c
+}
+
+func (c *Config) WithText(text, fontPath string, size float64, color, effect string) *Config {
+	c.Text = text
+	c.FontPath = fontPath
+	c.TextSize = size
+	c.TextColor = color
+	c.TextEffect = effect
+	return c
+}
+
+// ParseMargin parses a margin such as "16" (pixels) or "3%".
+func ParseMargin(s string) (float64, string, error) {
+	in := s
+	s = strings.TrimSpace(s)
+	unit := MarginPixels
+	if strings.HasSuffix(s, MarginPercent) {
+		unit = MarginPercent
+		s = strings.TrimSpace(strings.TrimSuffix(s, MarginPercent))
+	} else {
+		s = strings.TrimSuffix(s, MarginPixels)
+	}
+	margin, err := strconv.ParseFloat(s, 64)
+	if err != nil || margin < 0 {
+		return 0, "", fmt.Errorf("invalid margin %q, want pixels like 16 or a percentage like 3%%", in)
+	}
+	return margin, unit, nil
+}
+
+// FormatMargin is the inverse of ParseMargin.
+func FormatMargin(margin float64, unit string) string {
+	s := strconv.FormatFloat(margin, 'f', -1, 64)
+	if unit == MarginPercent {
+		return s + MarginPercent
+	}
+	return s
+}
+
+// ParseColor parses a color written as #RRGGBB or #RRGGBBAA.
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, want #RRGGBB or #RRGGBBAA", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
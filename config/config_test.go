@@ -1,6 +1,10 @@
 package config
 
 import (
+	"image/color"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -98,3 +102,131 @@ func TestWithMaxSize(t *testing.T) {
 		t.Errorf("WithMaxSize() = %+v, want MaxWidth=800, MaxHeight=600", newCfg)
 	}
 }
+
+func TestWithTargetSize(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.TargetSizeKB != 100 {
+		t.Errorf("DefaultConfig().TargetSizeKB = %d, want 100", cfg.TargetSizeKB)
+	}
+	if got := cfg.WithTargetSize(250); got.TargetSizeKB != 250 {
+		t.Errorf("WithTargetSize() = %+v, want TargetSizeKB=250", got)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Workers != runtime.NumCPU() {
+		t.Errorf("DefaultConfig().Workers = %d, want %d", cfg.Workers, runtime.NumCPU())
+	}
+	if got := cfg.WithWorkers(0); got.Workers != 1 {
+		t.Errorf("WithWorkers(0).Workers = %d, want 1", got.Workers)
+	}
+	if got := cfg.WithMemoryBudget(-5); got.MemoryBudgetMB != 0 {
+		t.Errorf("WithMemoryBudget(-5).MemoryBudgetMB = %d, want 0", got.MemoryBudgetMB)
+	}
+}
+
+func TestParseMargin(t *testing.T) {
+	tests := []struct {
+		in     string
+		margin float64
+		unit   string
+	}{
+		{"16", 16, MarginPixels},
+		{"16px", 16, MarginPixels},
+		{"3%", 3, MarginPercent},
+		{" 2.5 % ", 2.5, MarginPercent},
+	}
+	for _, tt := range tests {
+		margin, unit, err := ParseMargin(tt.in)
+		if err != nil || margin != tt.margin || unit != tt.unit {
+			t.Errorf("ParseMargin(%q) = %v, %q, %v; want %v, %q", tt.in, margin, unit, err, tt.margin, tt.unit)
+		}
+		if back, _, _ := ParseMargin(FormatMargin(margin, unit)); back != margin {
+			t.Errorf("FormatMargin(%v, %q) does not round-trip", margin, unit)
+		}
+	}
+	for _, in := range []string{"", "abc", "-4", "%"} {
+		if _, _, err := ParseMargin(in); err == nil {
+			t.Errorf("ParseMargin(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.NRGBA
+	}{
+		{"#FFFFFF", color.NRGBA{255, 255, 255, 255}},
+		{"#10203040", color.NRGBA{0x10, 0x20, 0x30, 0x40}},
+		{"ff8000", color.NRGBA{255, 128, 0, 255}},
+	}
+	for _, tt := range tests {
+		if got, err := ParseColor(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "#fff", "#GGGGGG", "#1234567"} {
+		if _, err := ParseColor(in); err == nil {
+			t.Errorf("ParseColor(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	got, err := ParseRecipients(strings.NewReader("email, ID ,Name\na@x.com,agency-a,Agency A\n# paused\nb@x.com,agency-b,\n"))
+	if err != nil {
+		t.Fatalf("ParseRecipients() error = %v", err)
+	}
+	want := []Recipient{{ID: "agency-a", Name: "Agency A"}, {ID: "agency-b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRecipients() = %+v, want %+v", got, want)
+	}
+
+	for _, in := range []string{"", "name\nA\n", "id\n", "id\nx\nX\n", "id\n../x\n", "id\n\"\"\n"} {
+		if _, err := ParseRecipients(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseRecipients(%q) expected error", in)
+		}
+	}
+}
+
+func TestForRecipient(t *testing.T) {
+	r := Recipient{ID: "a7", Name: "Agency"}
+	cfg := DefaultConfig().WithText("For "+RecipientToken, "", 5, "#FFFFFF", TextEffectNone).WithForensic("studio-" + RecipientIDToken)
+	got := cfg.ForRecipient(r)
+	if got.Text != "For Agency" || got.ForensicOwner != "studio-a7" || len(got.Layers) != 0 {
+		t.Errorf("ForRecipient() = text %q, owner %q, %d layers", got.Text, got.ForensicOwner, len(got.Layers))
+	}
+
+	// Without a token the label is added as a text layer.
+	got = DefaultConfig().WithLayers(NewLayer("logo.png", "anchor")).ForRecipient(Recipient{ID: "a7"})
+	if len(got.Layers) != 2 || got.Layers[1].Text != "a7" || got.Layers[1].Anchor != AnchorAuto {
+		t.Errorf("ForRecipient() layers = %+v", got.Layers)
+	}
+}
+
+func TestParseRenditions(t *testing.T) {
+	got, err := ParseRenditions("320:30, 640x480:60:85, x200, 1920")
+	if err != nil {
+		t.Fatalf("ParseRenditions() error = %v", err)
+	}
+	want := []Rendition{
+		{Width: 320, TargetSizeKB: 30},
+		{Width: 640, Height: 480, TargetSizeKB: 60, MaxQuality: 85},
+		{Height: 200},
+		{Width: 1920},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRenditions() = %+v, want %+v", got, want)
+	}
+	if suffix := got[1].Suffix(); suffix != "-640x480" {
+		t.Errorf("Suffix() = %q", suffix)
+	}
+
+	for _, in := range []string{"", "abc", "0", "320:30:101", "320,320:50", "320:1:2:3"} {
+		if _, err := ParseRenditions(in); err == nil {
+			t.Errorf("ParseRenditions(%q) expected error", in)
+		}
+	}
+}
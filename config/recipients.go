@@ -0,0 +1,132 @@
+package config
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Tokens in watermark texts and ForensicOwner that are replaced per
+// recipient.
+const (
+	RecipientToken   = "{recipient}"    // the recipient's name, or its ID without one
+	RecipientIDToken = "{recipient_id}" // the recipient's ID
+)
+
+// Recipient is one entry of a recipient list. Each recipient gets its own
+// copy of the output.
+type Recipient struct {
+	ID   string // names the recipient's output subfolder
+	Name string
+}
+
+// Label is the text shown for r in the watermark.
+func (r Recipient) Label() string {
+	if r.Name == "" {
+		return r.ID
+	}
+	return r.Name
+}
+
+func (r Recipient) expand(s string) string {
+	return strings.NewReplacer(RecipientIDToken, r.ID, RecipientToken, r.Label()).Replace(s)
+}
+
+// LoadRecipients reads a recipient list from a CSV file; see
+// ParseRecipients.
+func LoadRecipients(path string) ([]Recipient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening recipient list: %v", err)
+	}
+	defer f.Close()
+	recipients, err := ParseRecipients(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return recipients, nil
+}
+
+// ParseRecipients reads CSV with a header row naming an "id" column and
+// optionally a "name" column; other columns are ignored. IDs must be
+// unique and usable as folder names.
+func ParseRecipients(r io.Reader) ([]Recipient, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("recipient list is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	idCol, nameCol := slices.Index(header, "id"), slices.Index(header, "name")
+	if idCol < 0 {
+		return nil, fmt.Errorf("recipient list has no \"id\" column")
+	}
+
+	var recipients []Recipient
+	seen := make(map[string]bool)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		r := Recipient{ID: strings.TrimSpace(record[idCol])}
+		if nameCol >= 0 {
+			r.Name = strings.TrimSpace(record[nameCol])
+		}
+		switch {
+		case r.ID == "":
+			return nil, fmt.Errorf("line %d: recipient ID is empty", line)
+		case r.ID == "." || r.ID == ".." || strings.ContainsAny(r.ID, `/\:*?"<>|`):
+			return nil, fmt.Errorf("line %d: recipient ID %q can't be used as a folder name", line, r.ID)
+		case seen[strings.ToLower(r.ID)]:
+			return nil, fmt.Errorf("line %d: duplicate recipient ID %q", line, r.ID)
+		}
+		seen[strings.ToLower(r.ID)] = true
+		recipients = append(recipients, r)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("recipient list has no recipients")
+	}
+	return recipients, nil
+}
+
+// ForRecipient returns a copy of c for the export of r: the recipient
+// tokens in the watermark texts and ForensicOwner are replaced, and when no
+// text mentions the recipient, the recipient's label is added as a text
+// layer at the quietest anchor. The copy has no Recipients; its OutputDir
+// is left for the caller to set.
+func (c *Config) ForRecipient(r Recipient) *Config {
+	cfg := *c
+	cfg.Recipients = nil
+	cfg.Text = r.expand(c.Text)
+	cfg.ForensicOwner = r.expand(c.ForensicOwner)
+	mentioned := cfg.Text != c.Text
+	cfg.Layers = make([]Layer, len(c.Layers))
+	for i, l := range c.Layers {
+		l.Text = r.expand(l.Text)
+		mentioned = mentioned || l.Text != c.Layers[i].Text
+		cfg.Layers[i] = l
+	}
+	if !mentioned {
+		layer := NewLayer("", "anchor")
+		layer.Text = r.Label()
+		layer.Anchor = AnchorAuto
+		cfg.Layers = append(cfg.Layers, layer)
+	}
+	return &cfg
+}
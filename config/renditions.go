@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rendition is one output size made of every source when Config.Renditions
+// is set.
+type Rendition struct {
+	// Width and Height bound the rendition, keeping the aspect ratio of
+	// the image. Zero leaves that side unconstrained.
+	Width  int
+	Height int
+	// TargetSizeKB replaces Config.TargetSizeKB for this rendition unless
+	// it is zero.
+	TargetSizeKB int
+	// MaxQuality is the highest quality the size optimizer may pick for
+	// this rendition. Zero keeps Config.MaxQuality.
+	MaxQuality int
+}
+
+// Suffix is added to the output names of r, before the extension.
+func (r Rendition) Suffix() string {
+	switch {
+	case r.Height == 0:
+		return fmt.Sprintf("-%dw", r.Width)
+	case r.Width == 0:
+		return fmt.Sprintf("-%dh", r.Height)
+	default:
+		return fmt.Sprintf("-%dx%d", r.Width, r.Height)
+	}
+}
+
+func (r Rendition) validate() error {
+	switch {
+	case r.Width < 0 || r.Height < 0 || r.Width == 0 && r.Height == 0:
+		return fmt.Errorf("rendition needs a positive width or height")
+	case r.TargetSizeKB < 0:
+		return fmt.Errorf("rendition target size must not be negative")
+	case r.MaxQuality < 0 || r.MaxQuality > 100:
+		return fmt.Errorf("rendition maximum quality must be 1-100, or 0 to keep the global maximum")
+	}
+	return nil
+}
+
+// ValidateRenditions checks every rendition and that no two of them would
+// get the same output names.
+func ValidateRenditions(renditions []Rendition) error {
+	seen := make(map[string]bool)
+	for _, r := range renditions {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%s: %v", strings.TrimPrefix(r.Suffix(), "-"), err)
+		}
+		if seen[r.Suffix()] {
+			return fmt.Errorf("duplicate rendition %s", strings.TrimPrefix(r.Suffix(), "-"))
+		}
+		seen[r.Suffix()] = true
+	}
+	return nil
+}
+
+// ParseRenditions parses a comma-separated list of renditions, each
+// written as SIZE[:KB[:MAXQUALITY]] where SIZE is WIDTH, WIDTHxHEIGHT or
+// xHEIGHT, e.g. "320:30, 640:60, 1024, 1920:250:90".
+func ParseRenditions(s string) ([]Rendition, error) {
+	var renditions []Rendition
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parts := strings.Split(spec, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("invalid rendition %q, want SIZE[:KB[:MAXQUALITY]]", spec)
+		}
+		w, h, _ := strings.Cut(strings.ToLower(parts[0]), "x")
+		texts := []string{w, h, "", ""}
+		copy(texts[2:], parts[1:])
+		var r Rendition
+		for i, value := range []*int{&r.Width, &r.Height, &r.TargetSizeKB, &r.MaxQuality} {
+			if text := strings.TrimSpace(texts[i]); text != "" {
+				n, err := strconv.Atoi(text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid rendition %q, want SIZE[:KB[:MAXQUALITY]]", spec)
+				}
+				*value = n
+			}
+		}
+		renditions = append(renditions, r)
+	}
+	if len(renditions) == 0 {
+		return nil, fmt.Errorf("no renditions in %q", s)
+	}
+	if err := ValidateRenditions(renditions); err != nil {
+		return nil, err
+	}
+	return renditions, nil
+}
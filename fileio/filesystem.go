@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // decode WebP sources and outputs
 )
 
 func ReadDir(dir string) ([]os.DirEntry, error) {
@@ -13,13 +14,32 @@ func ReadDir(dir string) ([]os.DirEntry, error) {
 }
 
 func CreateDir(dir string) error {
-	err := os.Mkdir(dir, 0755)
+	err := os.MkdirAll(dir, 0755)
 	if err != nil && !os.IsExist(err) {
 		return fmt.Errorf("error creating directory: %v", err)
 	}
 	return nil
 }
 
+func Rename(oldPath, newPath string) error {
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("error renaming file: %v", err)
+	}
+	return nil
+}
+
+func Remove(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing file: %v", err)
+	}
+	return nil
+}
+
+func Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
 func LoadImage(path string) (image.Image, error) {
 	img, err := imaging.Open(path)
 	if err != nil {
@@ -27,3 +47,18 @@ func LoadImage(path string) (image.Image, error) {
 	}
 	return img, nil
 }
+
+// ImageSize reads the dimensions of an image from its header without
+// decoding the pixels.
+func ImageSize(path string) (int, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error opening image: %v", err)
+	}
+	defer file.Close()
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading image header: %v", err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
@@ -0,0 +1,43 @@
+package fileio
+
+import (
+	"image"
+	"os"
+
+	"github.com/del1x/GoIMGtool/config"
+)
+
+// Handler is the filesystem-backed FileHandler used by the GUI and the CLI.
+type Handler struct{}
+
+func (h *Handler) LoadImage(path string) (image.Image, error) {
+	return LoadImage(path)
+}
+
+func (h *Handler) SaveImage(img image.Image, path, format string, cfg *config.Config) (*SavedImage, error) {
+	return NewImageProcessor().SaveImage(img, path, format, cfg)
+}
+
+func (h *Handler) CreateDir(path string) error {
+	return CreateDir(path)
+}
+
+func (h *Handler) ReadDir(path string) ([]os.DirEntry, error) {
+	return ReadDir(path)
+}
+
+func (h *Handler) Rename(oldPath, newPath string) error {
+	return Rename(oldPath, newPath)
+}
+
+func (h *Handler) Remove(path string) error {
+	return Remove(path)
+}
+
+func (h *Handler) Exists(path string) bool {
+	return Exists(path)
+}
+
+func (h *Handler) ImageSize(path string) (int, int, error) {
+	return ImageSize(path)
+}
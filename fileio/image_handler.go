@@ -2,11 +2,140 @@ package fileio
 
 import (
 	"image"
+	"image/color"
+	"math"
 
 	"github.com/del1x/GoIMGtool/config"
 	"github.com/disintegration/imaging"
 )
 
+// HandleImageResize scales img down to fit within MaxWidth x MaxHeight or,
+// in the fill and pad modes, crops or pads it to exactly that size.
 func HandleImageResize(img image.Image, cfg *config.Config) image.Image {
-	return imaging.Fit(img, cfg.MaxWidth, cfg.MaxHeight, imaging.Lanczos)
+	switch cfg.ResizeMode {
+	case config.ResizeFill:
+		return fillImage(img, cfg)
+	case config.ResizePad:
+		return padImage(img, cfg)
+	default:
+		return imaging.Fit(img, cfg.MaxWidth, cfg.MaxHeight, imaging.Lanczos)
+	}
+}
+
+// fillImage scales and crops img to exactly MaxWidth x MaxHeight, keeping
+// the part at the configured crop anchor.
+func fillImage(img image.Image, cfg *config.Config) image.Image {
+	switch cfg.CropAnchor {
+	case config.CropTop:
+		return imaging.Fill(img, cfg.MaxWidth, cfg.MaxHeight, imaging.Top, imaging.Lanczos)
+	case config.CropAuto:
+		bounds := img.Bounds()
+		if bounds.Dx() == cfg.MaxWidth && bounds.Dy() == cfg.MaxHeight {
+			return img
+		}
+		crop := entropyCrop(img, cfg.MaxWidth, cfg.MaxHeight)
+		return imaging.Resize(imaging.Crop(img, crop), cfg.MaxWidth, cfg.MaxHeight, imaging.Lanczos)
+	default:
+		return imaging.Fill(img, cfg.MaxWidth, cfg.MaxHeight, imaging.Center, imaging.Lanczos)
+	}
+}
+
+// padImage scales img to fit the MaxWidth x MaxHeight canvas, enlarging it
+// if needed, and centers it on the configured background.
+func padImage(img image.Image, cfg *config.Config) image.Image {
+	width, height := cfg.MaxWidth, cfg.MaxHeight
+	bounds := img.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return img
+	}
+	scale := min(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	fitted := imaging.Resize(img,
+		max(1, int(math.Round(float64(bounds.Dx())*scale))),
+		max(1, int(math.Round(float64(bounds.Dy())*scale))),
+		imaging.Lanczos)
+
+	var canvas *image.NRGBA
+	if cfg.PadBackground == config.PadBlur {
+		// The blur radius grows with the canvas so the background looks the
+		// same at every output size.
+		canvas = imaging.Blur(imaging.Fill(img, width, height, imaging.Center, imaging.Linear), float64(max(width, height))/40)
+	} else {
+		background, err := config.ParseColor(cfg.PadBackground)
+		if err != nil {
+			background = color.NRGBA{255, 255, 255, 255}
+		}
+		canvas = imaging.New(width, height, background)
+	}
+	return imaging.OverlayCenter(canvas, fitted, 1)
+}
+
+// entropySample is the longer side of the thumbnail entropyCrop measures.
+const entropySample = 256
+
+// entropyCrop returns the largest region of img with the aspect ratio of
+// width x height that keeps the most detail. Like libvips' entropy crop, it
+// trims slices off whichever end of the longer axis has the lower luminance
+// entropy until the region has the right shape.
+func entropyCrop(img image.Image, width, height int) image.Rectangle {
+	bounds := img.Bounds()
+	small := imaging.Grayscale(imaging.Fit(img, entropySample, entropySample, imaging.Box))
+	sw, sh := small.Bounds().Dx(), small.Bounds().Dy()
+	scale := float64(bounds.Dx()) / float64(sw)
+
+	// The region in thumbnail pixels: x0..x1 by y0..y1.
+	x0, y0, x1, y1 := 0, 0, sw, sh
+	targetW := float64(sh) * float64(width) / float64(height)
+	targetH := float64(sw) * float64(height) / float64(width)
+	horizontal := targetW < float64(sw)
+	for {
+		var excess, step int
+		if horizontal {
+			excess = x1 - x0 - int(math.Round(targetW))
+		} else {
+			excess = y1 - y0 - int(math.Round(targetH))
+		}
+		if excess <= 0 {
+			break
+		}
+		step = min(excess, max(1, entropySample/32))
+		if horizontal {
+			if sliceEntropy(small, image.Rect(x0, y0, x0+step, y1)) < sliceEntropy(small, image.Rect(x1-step, y0, x1, y1)) {
+				x0 += step
+			} else {
+				x1 -= step
+			}
+		} else {
+			if sliceEntropy(small, image.Rect(x0, y0, x1, y0+step)) < sliceEntropy(small, image.Rect(x0, y1-step, x1, y1)) {
+				y0 += step
+			} else {
+				y1 -= step
+			}
+		}
+	}
+
+	// Map back to img with the exact aspect ratio of the target size.
+	cw := min(bounds.Dx(), int(math.Round(float64(bounds.Dy())*float64(width)/float64(height))))
+	ch := min(bounds.Dy(), int(math.Round(float64(bounds.Dx())*float64(height)/float64(width))))
+	cx := min(max(int(math.Round(float64(x0)*scale)), 0), bounds.Dx()-cw)
+	cy := min(max(int(math.Round(float64(y0)*scale)), 0), bounds.Dy()-ch)
+	return image.Rect(cx, cy, cx+cw, cy+ch).Add(bounds.Min)
+}
+
+// sliceEntropy is the Shannon entropy of the gray levels of gray inside r.
+func sliceEntropy(gray *image.NRGBA, r image.Rectangle) float64 {
+	var hist [256]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			hist[gray.Pix[y*gray.Stride+x*4]]++
+		}
+	}
+	n := float64(r.Dx() * r.Dy())
+	var entropy float64
+	for _, count := range hist {
+		if count > 0 {
+			p := float64(count) / n
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
 }
@@ -1,6 +1,7 @@
 package fileio
 
 import (
+	"errors"
 	"fmt"
 	"image"
 	"os"
@@ -10,17 +11,30 @@ import (
 	"github.com/del1x/GoIMGtool/config"
 )
 
+// ErrTargetSizeExceeded is returned by SaveImage when the written file is
+// larger than the configured target size.
+var ErrTargetSizeExceeded = errors.New("target size exceeded")
+
 type ImageProcessor struct {
 	TargetSizeKB int
 }
 
+// SavedImage describes a file written by SaveImage.
+type SavedImage struct {
+	Path    string
+	Width   int
+	Height  int
+	Quality int
+	Size    int64 // bytes
+}
+
 func NewImageProcessor() *ImageProcessor {
 	return &ImageProcessor{
 		TargetSizeKB: 100,
 	}
 }
 
-func (p *ImageProcessor) SaveImage(img image.Image, outputPath, outputFormat string, cfg *config.Config) error {
+func (p *ImageProcessor) SaveImage(img image.Image, outputPath, outputFormat string, cfg *config.Config) (*SavedImage, error) {
 	fmt.Println("Processing image with format:", outputFormat)
 	base := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
 	outputPath = base + "." + outputFormat
@@ -28,35 +42,50 @@ func (p *ImageProcessor) SaveImage(img image.Image, outputPath, outputFormat str
 	img = HandleImageResize(img, cfg)
 	fmt.Println("Image resized, type:", fmt.Sprintf("%T", img))
 
-	bestQuality, err := OptimizeQuality(img, outputFormat, base, p.TargetSizeKB)
+	targetSizeKB, minQuality, maxQuality := p.TargetSizeKB, 0, 0
+	if cfg != nil && cfg.TargetSizeKB > 0 {
+		targetSizeKB = cfg.TargetSizeKB
+	}
+	if cfg != nil {
+		minQuality, maxQuality = cfg.MinQuality, cfg.MaxQuality
+	}
+
+	bestQuality, err := OptimizeQuality(img, outputFormat, base, targetSizeKB, minQuality, maxQuality)
 	if err != nil {
-		return fmt.Errorf("error optimizing quality: %v", err)
+		return nil, fmt.Errorf("error optimizing quality: %v", err)
 	}
 	fmt.Println("Optimized quality:", bestQuality)
 
 	file, err := os.Create(outputPath)
 	if err != nil {
-		return fmt.Errorf("error creating file: %v", err)
+		return nil, fmt.Errorf("error creating file: %v", err)
 	}
 	defer file.Close()
 
 	encoder, err := GetEncoder(outputFormat)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err := encoder.Encode(img, file, bestQuality); err != nil {
-		return fmt.Errorf("error encoding image: %v", err)
+		return nil, fmt.Errorf("error encoding image: %v", err)
 	}
 
 	fileInfo, err := os.Stat(outputPath)
 	if err != nil {
-		return fmt.Errorf("error getting file info: %v", err)
+		return nil, fmt.Errorf("error getting file info: %v", err)
+	}
+	saved := &SavedImage{
+		Path:    outputPath,
+		Width:   img.Bounds().Dx(),
+		Height:  img.Bounds().Dy(),
+		Quality: bestQuality,
+		Size:    fileInfo.Size(),
 	}
 	fileSizeKB := fileInfo.Size() / 1024
 	fmt.Printf("Processed image: %s with size %d KB and quality: %d\n", outputPath, fileSizeKB, bestQuality)
-	if int64(fileSizeKB) > int64(p.TargetSizeKB) {
-		return fmt.Errorf("final size %d KB exceeds %d KB limit", fileSizeKB, p.TargetSizeKB)
+	if int64(fileSizeKB) > int64(targetSizeKB) {
+		return saved, fmt.Errorf("%w: final size %d KB exceeds %d KB limit", ErrTargetSizeExceeded, fileSizeKB, targetSizeKB)
 	}
 
-	return nil
+	return saved, nil
 }
@@ -18,12 +18,21 @@ func saveAndGetSize(img image.Image, quality int, format string) (int, error) {
 	return buf.Len() / 1024, nil
 }
 
-func OptimizeQuality(img image.Image, outputFormat, base string, targetSizeKB int) (int, error) {
+// OptimizeQuality finds the highest quality from minQuality (1 when zero) up
+// to maxQuality (100 when zero) whose encoding fits in targetSizeKB. When
+// even minQuality is too large and minQuality is set, it returns minQuality:
+// the floor takes precedence over the size, and the caller reports the
+// missed target.
+func OptimizeQuality(img image.Image, outputFormat, base string, targetSizeKB, minQuality, maxQuality int) (int, error) {
 	if outputFormat == "png" {
 		return 80, nil
 	}
 
-	low, high, bestQuality := 1, 100, 0
+	high := 100
+	if maxQuality > 0 {
+		high = min(maxQuality, 100)
+	}
+	low, bestQuality := max(minQuality, 1), 0
 	for low <= high {
 		mid := low + (high-low)/2
 		size, err := saveAndGetSize(img, mid, outputFormat)
@@ -39,6 +48,9 @@ func OptimizeQuality(img image.Image, outputFormat, base string, targetSizeKB in
 		}
 	}
 
+	if bestQuality == 0 && minQuality > 1 {
+		return min(minQuality, 100), nil
+	}
 	if bestQuality == 0 {
 		return 1, fmt.Errorf("could not optimize quality for %s to fit %d KB", outputFormat, targetSizeKB)
 	}
@@ -1,11 +1,16 @@
 package gui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -20,9 +25,12 @@ import (
 
 type FileHandler interface {
 	LoadImage(path string) (image.Image, error)
-	SaveImage(img image.Image, path, format string, cfg *config.Config) error
+	SaveImage(img image.Image, path, format string, cfg *config.Config) (*fileio.SavedImage, error)
 	CreateDir(path string) error
 	ReadDir(path string) ([]os.DirEntry, error)
+	Rename(oldPath, newPath string) error
+	Remove(path string) error
+	Exists(path string) bool
 }
 
 type GUI struct {
@@ -32,17 +40,23 @@ type GUI struct {
 	watermarkMode string
 	currentLocale string
 	components    *GUIComponents
+	cancel        context.CancelFunc
+	previewMu     sync.Mutex // decodes one preview at a time
 }
 
+// watermarkExtensions are the file types accepted as watermark images.
+var watermarkExtensions = []string{".png", ".jpg", ".jpeg", ".webp", ".svg"}
+
 type GUIComponents struct {
-	widthLabel, heightLabel, watermarkLabel, imageDirLabel, formatLabel, qualityLabel, targetSizeLabel, watermarkModeLabel, languageLabel, webSizeHintLabel *widget.Label
-	widthEntry, heightEntry, qualityEntry, targetSizeEntry, watermarkEntry, imageDirEntry                                                                   *widget.Entry
-	formatSelect, watermarkModeSelect, languageSelect                                                                                                       *widget.Select
-	currentFileLabel                                                                                                                                        *widget.Label
-	progress                                                                                                                                                *widget.ProgressBar
-	imageContainer                                                                                                                                          *fyne.Container
-	scrollContainer                                                                                                                                         *container.Scroll
-	fileButton, folderButton                                                                                                                                *widget.Button
+	widthLabel, heightLabel, watermarkLabel, imageDirLabel, formatLabel, qualityLabel, targetSizeLabel, watermarkModeLabel, languageLabel, webSizeHintLabel, workersLabel, memoryBudgetLabel, maxDepthLabel, outputDirLabel, nameTemplateLabel, collisionLabel, anchorLabel, marginLabel, scaleLabel, tileSpacingLabel, tileOffsetLabel, opacityLabel, textLabel, fontLabel, textSizeLabel, textColorLabel, textEffectLabel, blendModeLabel, orientationLabel, forensicLabel, recipientsLabel, resizeModeLabel, cropAnchorLabel, padLabel, renditionsLabel *widget.Label
+	widthEntry, heightEntry, qualityEntry, targetSizeEntry, watermarkEntry, imageDirEntry, workersEntry, memoryBudgetEntry, maxDepthEntry, outputDirEntry, nameTemplateEntry, marginEntry, scaleEntry, tileSpacingEntry, tileOffsetXEntry, tileOffsetYEntry, opacityEntry, textEntry, fontEntry, textSizeEntry, textColorEntry, variantEntry, portraitEntry, landscapeEntry, squareEntry, forensicEntry, recipientsEntry, padEntry, renditionsEntry                                                                                                        *widget.Entry
+	formatSelect, watermarkModeSelect, languageSelect, collisionSelect, anchorSelect, textEffectSelect, blendModeSelect, resizeModeSelect, cropAnchorSelect                                                                                                                                                                                                                                                                                                                                                                                                *widget.Select
+	currentFileLabel                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                       *widget.Label
+	progress                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                               *widget.ProgressBar
+	imageContainer, anchorSettings, scaleSettings, tileSettings                                                                                                                                                                                                                                                                                                                                                                                                                                                                                            *fyne.Container
+	scrollContainer                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                        *container.Scroll
+	fileButton, folderButton, processButton, cancelButton, resumeButton, fontButton, variantButton, portraitButton, landscapeButton, squareButton, recipientsButton                                                                                                                                                                                                                                                                                                                                                                                        *widget.Button
+	recursiveCheck, skipHiddenCheck, incrementalCheck, pruneCheck, tileRotateCheck, adaptiveCheck                                                                                                                                                                                                                                                                                                                                                                                                                                                          *widget.Check
 }
 
 func NewGUI(window fyne.Window, cfg *config.Config, fileHandler FileHandler) *GUI {
@@ -61,15 +75,52 @@ func (g *GUI) Setup() {
 	content := container.NewVBox(
 		g.components.languageLabel, g.components.languageSelect,
 		g.components.watermarkLabel, g.components.watermarkEntry, g.components.fileButton,
+		g.components.orientationLabel,
+		container.NewBorder(nil, nil, nil, g.components.portraitButton, g.components.portraitEntry),
+		container.NewBorder(nil, nil, nil, g.components.landscapeButton, g.components.landscapeEntry),
+		container.NewBorder(nil, nil, nil, g.components.squareButton, g.components.squareEntry),
+		g.components.textLabel, g.components.textEntry,
+		g.components.fontLabel, g.components.fontEntry, g.components.fontButton,
+		container.NewGridWithColumns(3,
+			container.NewVBox(g.components.textSizeLabel, g.components.textSizeEntry),
+			container.NewVBox(g.components.textColorLabel, g.components.textColorEntry),
+			container.NewVBox(g.components.textEffectLabel, g.components.textEffectSelect),
+		),
 		g.components.imageDirLabel, g.components.imageDirEntry, g.components.folderButton,
+		container.NewGridWithColumns(2, g.components.recursiveCheck, g.components.skipHiddenCheck),
+		g.components.maxDepthLabel, g.components.maxDepthEntry,
+		g.components.outputDirLabel, g.components.outputDirEntry,
+		g.components.nameTemplateLabel, g.components.nameTemplateEntry,
+		g.components.collisionLabel, g.components.collisionSelect,
+		container.NewGridWithColumns(2, g.components.incrementalCheck, g.components.pruneCheck),
 		g.components.formatLabel, g.components.formatSelect,
 		g.components.qualityLabel, g.components.qualityEntry,
 		g.components.webSizeHintLabel,
 		g.components.widthLabel, g.components.widthEntry,
 		g.components.heightLabel, g.components.heightEntry,
+		container.NewGridWithColumns(3,
+			container.NewVBox(g.components.resizeModeLabel, g.components.resizeModeSelect),
+			container.NewVBox(g.components.cropAnchorLabel, g.components.cropAnchorSelect),
+			container.NewVBox(g.components.padLabel, g.components.padEntry),
+		),
+		g.components.renditionsLabel, g.components.renditionsEntry,
 		g.components.targetSizeLabel, g.components.targetSizeEntry,
+		g.components.workersLabel, g.components.workersEntry,
+		g.components.memoryBudgetLabel, g.components.memoryBudgetEntry,
+		container.NewGridWithColumns(2,
+			container.NewVBox(g.components.opacityLabel, g.components.opacityEntry),
+			container.NewVBox(g.components.blendModeLabel, g.components.blendModeSelect),
+		),
+		g.components.adaptiveCheck,
+		container.NewBorder(nil, nil, nil, g.components.variantButton, g.components.variantEntry),
+		g.components.forensicLabel, g.components.forensicEntry,
+		g.components.recipientsLabel,
+		container.NewBorder(nil, nil, nil, g.components.recipientsButton, g.components.recipientsEntry),
 		g.components.watermarkModeLabel, g.components.watermarkModeSelect,
-		g.createProcessButton(),
+		g.components.anchorSettings,
+		g.components.tileSettings,
+		g.components.scaleSettings,
+		container.NewGridWithColumns(3, g.components.processButton, g.components.resumeButton, g.components.cancelButton),
 		g.components.currentFileLabel,
 		g.components.progress,
 		g.components.scrollContainer,
@@ -90,6 +141,31 @@ func (g *GUI) initComponents() {
 	g.components.watermarkModeLabel = widget.NewLabel(locales[g.currentLocale].WatermarkModeLabel)
 	g.components.currentFileLabel = widget.NewLabel(locales[g.currentLocale].CurrentFileLabel)
 	g.components.webSizeHintLabel = widget.NewLabel(locales[g.currentLocale].WebSizeHint)
+	g.components.workersLabel = widget.NewLabel(locales[g.currentLocale].WorkersLabel)
+	g.components.memoryBudgetLabel = widget.NewLabel(locales[g.currentLocale].MemoryBudgetLabel)
+	g.components.maxDepthLabel = widget.NewLabel(locales[g.currentLocale].MaxDepthLabel)
+	g.components.outputDirLabel = widget.NewLabel(locales[g.currentLocale].OutputDirLabel)
+	g.components.nameTemplateLabel = widget.NewLabel(locales[g.currentLocale].NameTemplateLabel)
+	g.components.collisionLabel = widget.NewLabel(locales[g.currentLocale].CollisionLabel)
+	g.components.resizeModeLabel = widget.NewLabel(locales[g.currentLocale].ResizeModeLabel)
+	g.components.cropAnchorLabel = widget.NewLabel(locales[g.currentLocale].CropAnchorLabel)
+	g.components.padLabel = widget.NewLabel(locales[g.currentLocale].PadLabel)
+	g.components.renditionsLabel = widget.NewLabel(locales[g.currentLocale].RenditionsLabel)
+	g.components.renditionsEntry = widget.NewEntry()
+	g.components.renditionsEntry.SetPlaceHolder(locales[g.currentLocale].RenditionsPlaceholder)
+	g.components.anchorLabel = widget.NewLabel(locales[g.currentLocale].AnchorLabel)
+	g.components.marginLabel = widget.NewLabel(locales[g.currentLocale].MarginLabel)
+	g.components.scaleLabel = widget.NewLabel(locales[g.currentLocale].ScaleLabel)
+	g.components.tileSpacingLabel = widget.NewLabel(locales[g.currentLocale].TileSpacingLabel)
+	g.components.tileOffsetLabel = widget.NewLabel(locales[g.currentLocale].TileOffsetLabel)
+	g.components.opacityLabel = widget.NewLabel(locales[g.currentLocale].OpacityLabel)
+	g.components.orientationLabel = widget.NewLabel(locales[g.currentLocale].OrientationLabel)
+	g.components.blendModeLabel = widget.NewLabel(locales[g.currentLocale].BlendModeLabel)
+	g.components.textLabel = widget.NewLabel(locales[g.currentLocale].TextLabel)
+	g.components.fontLabel = widget.NewLabel(locales[g.currentLocale].FontLabel)
+	g.components.textSizeLabel = widget.NewLabel(locales[g.currentLocale].TextSizeLabel)
+	g.components.textColorLabel = widget.NewLabel(locales[g.currentLocale].TextColorLabel)
+	g.components.textEffectLabel = widget.NewLabel(locales[g.currentLocale].TextEffectLabel)
 
 	g.components.watermarkEntry = widget.NewEntry()
 	g.components.watermarkEntry.SetPlaceHolder(locales[g.currentLocale].WatermarkPlaceholder)
@@ -97,6 +173,147 @@ func (g *GUI) initComponents() {
 	g.components.imageDirEntry = widget.NewEntry()
 	g.components.imageDirEntry.SetPlaceHolder(locales[g.currentLocale].ImageDirPlaceholder)
 
+	g.components.portraitEntry = widget.NewEntry()
+	g.components.portraitEntry.SetPlaceHolder(locales[g.currentLocale].PortraitPlaceholder)
+	g.components.portraitEntry.OnChanged = func(s string) {
+		g.cfg.PortraitWatermark = s
+	}
+
+	g.components.landscapeEntry = widget.NewEntry()
+	g.components.landscapeEntry.SetPlaceHolder(locales[g.currentLocale].LandscapePlaceholder)
+	g.components.landscapeEntry.OnChanged = func(s string) {
+		g.cfg.LandscapeWatermark = s
+	}
+
+	g.components.squareEntry = widget.NewEntry()
+	g.components.squareEntry.SetPlaceHolder(locales[g.currentLocale].SquarePlaceholder)
+	g.components.squareEntry.OnChanged = func(s string) {
+		g.cfg.SquareWatermark = s
+	}
+
+	g.components.forensicLabel = widget.NewLabel(locales[g.currentLocale].ForensicLabel)
+	g.components.forensicEntry = widget.NewEntry()
+	g.components.forensicEntry.SetPlaceHolder(locales[g.currentLocale].ForensicPlaceholder)
+	g.components.forensicEntry.OnChanged = func(s string) {
+		if len(s) <= processor.ForensicOwnerMax {
+			g.cfg.ForensicOwner = s
+		} else {
+			g.components.forensicEntry.SetText(g.cfg.ForensicOwner)
+		}
+	}
+
+	g.components.recipientsLabel = widget.NewLabel(locales[g.currentLocale].RecipientsLabel)
+	g.components.recipientsEntry = widget.NewEntry()
+	g.components.recipientsEntry.SetPlaceHolder(locales[g.currentLocale].RecipientsPlaceholder)
+
+	g.components.textEntry = widget.NewEntry()
+	g.components.textEntry.SetPlaceHolder(locales[g.currentLocale].TextPlaceholder)
+	g.components.textEntry.OnChanged = func(s string) {
+		g.cfg.Text = s
+	}
+
+	g.components.fontEntry = widget.NewEntry()
+	g.components.fontEntry.SetText(g.cfg.FontPath)
+	g.components.fontEntry.OnChanged = func(s string) {
+		g.cfg.FontPath = s
+	}
+
+	g.components.textSizeEntry = widget.NewEntry()
+	g.components.textSizeEntry.SetText(strconv.FormatFloat(g.cfg.TextSize, 'f', -1, 64))
+	g.components.textSizeEntry.OnChanged = func(s string) {
+		if size, err := strconv.ParseFloat(s, 64); err == nil && size > 0 && size <= 100 {
+			g.cfg.TextSize = size
+		} else {
+			g.components.textSizeEntry.SetText(strconv.FormatFloat(g.cfg.TextSize, 'f', -1, 64))
+		}
+	}
+
+	// Colors are checked when processing starts, since partial input like
+	// "#FF" is not valid yet.
+	g.components.textColorEntry = widget.NewEntry()
+	g.components.textColorEntry.SetText(g.cfg.TextColor)
+	g.components.textColorEntry.OnChanged = func(s string) {
+		g.cfg.TextColor = s
+	}
+
+	g.components.textEffectSelect = widget.NewSelect([]string{config.TextEffectNone, config.TextEffectOutline, config.TextEffectShadow}, func(s string) {
+		g.cfg.TextEffect = s
+	})
+	g.components.textEffectSelect.SetSelected(g.cfg.TextEffect)
+
+	g.components.outputDirEntry = widget.NewEntry()
+	g.components.outputDirEntry.SetText(g.cfg.OutputDir)
+	g.components.outputDirEntry.OnChanged = func(s string) {
+		g.cfg.OutputDir = s
+	}
+
+	g.components.nameTemplateEntry = widget.NewEntry()
+	g.components.nameTemplateEntry.SetText(g.cfg.NameTemplate)
+	g.components.nameTemplateEntry.OnChanged = func(s string) {
+		g.cfg.NameTemplate = s
+	}
+
+	g.components.collisionSelect = widget.NewSelect([]string{config.CollisionOverwrite, config.CollisionSkip, config.CollisionSuffix, config.CollisionFail}, func(s string) {
+		g.cfg.Collision = s
+	})
+	g.components.collisionSelect.SetSelected(g.cfg.Collision)
+
+	g.components.cropAnchorSelect = widget.NewSelect(config.CropAnchors, func(s string) {
+		g.cfg.CropAnchor = s
+	})
+	g.components.cropAnchorSelect.SetSelected(g.cfg.CropAnchor)
+	// The background is checked when processing starts, like the text color.
+	g.components.padEntry = widget.NewEntry()
+	g.components.padEntry.SetPlaceHolder(locales[g.currentLocale].PadPlaceholder)
+	g.components.padEntry.SetText(g.cfg.PadBackground)
+	g.components.padEntry.OnChanged = func(s string) {
+		g.cfg.PadBackground = s
+	}
+	g.components.resizeModeSelect = widget.NewSelect(config.ResizeModes, func(s string) {
+		g.cfg.ResizeMode = s
+		if s == config.ResizeFill {
+			g.components.cropAnchorSelect.Enable()
+		} else {
+			g.components.cropAnchorSelect.Disable()
+		}
+		if s == config.ResizePad {
+			g.components.padEntry.Enable()
+		} else {
+			g.components.padEntry.Disable()
+		}
+	})
+	g.components.resizeModeSelect.SetSelected(g.cfg.ResizeMode)
+
+	g.components.incrementalCheck = widget.NewCheck(locales[g.currentLocale].IncrementalCheck, func(b bool) {
+		g.cfg.Incremental = b
+	})
+	g.components.incrementalCheck.SetChecked(g.cfg.Incremental)
+
+	g.components.pruneCheck = widget.NewCheck(locales[g.currentLocale].PruneCheck, func(b bool) {
+		g.cfg.PruneOrphans = b
+	})
+	g.components.pruneCheck.SetChecked(g.cfg.PruneOrphans)
+
+	g.components.recursiveCheck = widget.NewCheck(locales[g.currentLocale].RecursiveCheck, func(b bool) {
+		g.cfg.Recursive = b
+	})
+	g.components.recursiveCheck.SetChecked(g.cfg.Recursive)
+
+	g.components.skipHiddenCheck = widget.NewCheck(locales[g.currentLocale].SkipHiddenCheck, func(b bool) {
+		g.cfg.SkipHidden = b
+	})
+	g.components.skipHiddenCheck.SetChecked(g.cfg.SkipHidden)
+
+	g.components.maxDepthEntry = widget.NewEntry()
+	g.components.maxDepthEntry.SetText(strconv.Itoa(g.cfg.MaxDepth))
+	g.components.maxDepthEntry.OnChanged = func(s string) {
+		if d, err := strconv.Atoi(s); err == nil && d >= 0 {
+			g.cfg.MaxDepth = d
+		} else {
+			g.components.maxDepthEntry.SetText(strconv.Itoa(g.cfg.MaxDepth))
+		}
+	}
+
 	g.components.formatSelect = widget.NewSelect([]string{"jpg", "webp", "png"}, func(s string) {
 		g.cfg.OutputFormat = s
 	})
@@ -116,7 +333,7 @@ func (g *GUI) initComponents() {
 	g.components.widthEntry.SetText(strconv.Itoa(g.cfg.MaxWidth))
 	g.components.widthEntry.OnChanged = func(s string) {
 		if w, err := strconv.Atoi(s); err == nil && w >= 1 {
-			if g.components.watermarkEntry.Text != "" {
+			if g.watermarkCapsSize() && g.components.watermarkEntry.Text != "" {
 				if img, err := g.fileHandler.LoadImage(g.components.watermarkEntry.Text); err == nil && img != nil {
 					if w > img.Bounds().Dx() {
 						dialog.ShowInformation(locales[g.currentLocale].ErrorTitle, fmt.Sprintf(locales[g.currentLocale].WidthExceedsWatermark, img.Bounds().Dx()), g.window)
@@ -136,7 +353,7 @@ func (g *GUI) initComponents() {
 	g.components.heightEntry.SetText(strconv.Itoa(g.cfg.MaxHeight))
 	g.components.heightEntry.OnChanged = func(s string) {
 		if h, err := strconv.Atoi(s); err == nil && h >= 1 {
-			if g.components.watermarkEntry.Text != "" {
+			if g.watermarkCapsSize() && g.components.watermarkEntry.Text != "" {
 				if img, err := g.fileHandler.LoadImage(g.components.watermarkEntry.Text); err == nil && img != nil {
 					if h > img.Bounds().Dy() {
 						dialog.ShowInformation(locales[g.currentLocale].ErrorTitle, fmt.Sprintf(locales[g.currentLocale].HeightExceedsWatermark, img.Bounds().Dy()), g.window)
@@ -153,17 +370,140 @@ func (g *GUI) initComponents() {
 	}
 
 	g.components.targetSizeEntry = widget.NewEntry()
-	g.components.targetSizeEntry.SetText("100")
+	g.components.targetSizeEntry.SetText(strconv.Itoa(g.cfg.TargetSizeKB))
 	g.components.targetSizeEntry.OnChanged = func(s string) {
 		if size, err := strconv.Atoi(s); err == nil && size >= 50 && size <= 5000 {
-			fileio.NewImageProcessor().TargetSizeKB = size
+			g.cfg.TargetSizeKB = size
 		} else {
-			g.components.targetSizeEntry.SetText("100")
+			g.components.targetSizeEntry.SetText(strconv.Itoa(g.cfg.TargetSizeKB))
+		}
+	}
+
+	g.components.workersEntry = widget.NewEntry()
+	g.components.workersEntry.SetText(strconv.Itoa(g.cfg.Workers))
+	g.components.workersEntry.OnChanged = func(s string) {
+		if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= 256 {
+			g.cfg.Workers = n
+		} else {
+			g.components.workersEntry.SetText(strconv.Itoa(g.cfg.Workers))
+		}
+	}
+
+	g.components.memoryBudgetEntry = widget.NewEntry()
+	g.components.memoryBudgetEntry.SetText(strconv.Itoa(g.cfg.MemoryBudgetMB))
+	g.components.memoryBudgetEntry.OnChanged = func(s string) {
+		if mb, err := strconv.Atoi(s); err == nil && mb >= 0 {
+			g.cfg.MemoryBudgetMB = mb
+		} else {
+			g.components.memoryBudgetEntry.SetText(strconv.Itoa(g.cfg.MemoryBudgetMB))
+		}
+	}
+
+	g.components.opacityEntry = widget.NewEntry()
+	g.components.opacityEntry.SetText(strconv.Itoa(g.cfg.Opacity))
+	g.components.opacityEntry.OnChanged = func(s string) {
+		if o, err := strconv.Atoi(s); err == nil && o >= 0 && o <= 100 {
+			g.cfg.Opacity = o
+		} else {
+			g.components.opacityEntry.SetText(strconv.Itoa(g.cfg.Opacity))
+		}
+	}
+
+	g.components.blendModeSelect = widget.NewSelect(config.BlendModes, func(s string) {
+		g.cfg.BlendMode = s
+	})
+	g.components.blendModeSelect.SetSelected(g.cfg.BlendMode)
+
+	g.components.variantEntry = widget.NewEntry()
+	g.components.variantEntry.SetPlaceHolder(locales[g.currentLocale].VariantPlaceholder)
+	g.components.variantEntry.SetText(g.cfg.AdaptiveVariant)
+	g.components.variantEntry.OnChanged = func(s string) {
+		g.cfg.AdaptiveVariant = s
+	}
+
+	g.components.adaptiveCheck = widget.NewCheck(locales[g.currentLocale].AdaptiveCheck, func(b bool) {
+		g.cfg.Adaptive = b
+		setVisible(g.components.variantEntry, b)
+		setVisible(g.components.variantButton, b)
+	})
+
+	g.components.anchorSelect = widget.NewSelect(config.Anchors, func(s string) {
+		g.cfg.Anchor = s
+	})
+	g.components.anchorSelect.SetSelected(g.cfg.Anchor)
+
+	g.components.marginEntry = widget.NewEntry()
+	g.components.marginEntry.SetText(config.FormatMargin(g.cfg.Margin, g.cfg.MarginUnit))
+	g.components.marginEntry.OnChanged = func(s string) {
+		if margin, unit, err := config.ParseMargin(s); err == nil {
+			g.cfg.Margin, g.cfg.MarginUnit = margin, unit
+		} else {
+			g.components.marginEntry.SetText(config.FormatMargin(g.cfg.Margin, g.cfg.MarginUnit))
+		}
+	}
+
+	g.components.scaleEntry = widget.NewEntry()
+	g.components.scaleEntry.SetText(strconv.FormatFloat(g.cfg.Scale, 'f', -1, 64))
+	g.components.scaleEntry.OnChanged = func(s string) {
+		if scale, err := strconv.ParseFloat(s, 64); err == nil && scale >= 0 && scale <= 100 {
+			g.cfg.Scale = scale
+		} else {
+			g.components.scaleEntry.SetText(strconv.FormatFloat(g.cfg.Scale, 'f', -1, 64))
+		}
+	}
+
+	g.components.tileSpacingEntry = widget.NewEntry()
+	g.components.tileSpacingEntry.SetText(strconv.Itoa(g.cfg.TileSpacing))
+	g.components.tileSpacingEntry.OnChanged = func(s string) {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			g.cfg.TileSpacing = n
+		} else {
+			g.components.tileSpacingEntry.SetText(strconv.Itoa(g.cfg.TileSpacing))
+		}
+	}
+
+	g.components.tileOffsetXEntry = widget.NewEntry()
+	g.components.tileOffsetXEntry.SetText(strconv.Itoa(g.cfg.TileOffsetX))
+	g.components.tileOffsetXEntry.OnChanged = func(s string) {
+		if n, err := strconv.Atoi(s); err == nil {
+			g.cfg.TileOffsetX = n
+		} else if s != "-" {
+			g.components.tileOffsetXEntry.SetText(strconv.Itoa(g.cfg.TileOffsetX))
+		}
+	}
+
+	g.components.tileOffsetYEntry = widget.NewEntry()
+	g.components.tileOffsetYEntry.SetText(strconv.Itoa(g.cfg.TileOffsetY))
+	g.components.tileOffsetYEntry.OnChanged = func(s string) {
+		if n, err := strconv.Atoi(s); err == nil {
+			g.cfg.TileOffsetY = n
+		} else if s != "-" {
+			g.components.tileOffsetYEntry.SetText(strconv.Itoa(g.cfg.TileOffsetY))
 		}
 	}
 
-	g.components.watermarkModeSelect = widget.NewSelect([]string{"crop", "resize"}, func(s string) {
+	g.components.tileRotateCheck = widget.NewCheck(locales[g.currentLocale].TileRotateCheck, func(b bool) {
+		g.cfg.TileRotate = b
+	})
+	g.components.tileRotateCheck.SetChecked(g.cfg.TileRotate)
+
+	// Placement settings are shown only for the modes that use them.
+	g.components.anchorSettings = container.NewVBox(
+		g.components.anchorLabel, g.components.anchorSelect,
+		g.components.marginLabel, g.components.marginEntry,
+	)
+	g.components.tileSettings = container.NewVBox(
+		container.NewGridWithColumns(2,
+			container.NewVBox(g.components.tileSpacingLabel, g.components.tileSpacingEntry),
+			container.NewVBox(g.components.tileOffsetLabel, container.NewGridWithColumns(2, g.components.tileOffsetXEntry, g.components.tileOffsetYEntry)),
+		),
+		g.components.tileRotateCheck,
+	)
+	g.components.scaleSettings = container.NewVBox(g.components.scaleLabel, g.components.scaleEntry)
+
+	g.components.watermarkModeSelect = widget.NewSelect([]string{"crop", "resize", "anchor", "tile"}, func(s string) {
 		g.watermarkMode = s
+		g.updateModeSettings()
 	})
 	g.components.watermarkModeSelect.SetSelected("crop")
 
@@ -173,7 +513,19 @@ func (g *GUI) initComponents() {
 	g.components.scrollContainer.SetMinSize(fyne.NewSize(400, 200))
 
 	g.components.fileButton = g.createFileButton()
+	g.components.fontButton = g.createBrowseButton(locales[g.currentLocale].BrowseFontButton, g.components.fontEntry, []string{".ttf", ".otf"})
+	g.components.variantButton = g.createBrowseButton(locales[g.currentLocale].BrowseButton, g.components.variantEntry, watermarkExtensions)
+	g.components.portraitButton = g.createBrowseButton(locales[g.currentLocale].BrowseButton, g.components.portraitEntry, watermarkExtensions)
+	g.components.landscapeButton = g.createBrowseButton(locales[g.currentLocale].BrowseButton, g.components.landscapeEntry, watermarkExtensions)
+	g.components.squareButton = g.createBrowseButton(locales[g.currentLocale].BrowseButton, g.components.squareEntry, watermarkExtensions)
+	g.components.recipientsButton = g.createBrowseButton(locales[g.currentLocale].BrowseButton, g.components.recipientsEntry, []string{".csv"})
+	g.components.adaptiveCheck.SetChecked(g.cfg.Adaptive)
+	setVisible(g.components.variantEntry, g.cfg.Adaptive)
+	setVisible(g.components.variantButton, g.cfg.Adaptive)
 	g.components.folderButton = g.createFolderButton()
+	g.components.processButton = g.createProcessButton()
+	g.components.cancelButton = g.createCancelButton()
+	g.components.resumeButton = g.createResumeButton()
 
 	g.components.languageSelect = widget.NewSelect([]string{"English", "Русский"}, func(s string) {
 		if s == "English" {
@@ -186,6 +538,20 @@ func (g *GUI) initComponents() {
 	g.components.languageSelect.SetSelected("English")
 }
 
+func (g *GUI) updateModeSettings() {
+	setVisible(g.components.anchorSettings, g.watermarkMode == "anchor")
+	setVisible(g.components.tileSettings, g.watermarkMode == "tile")
+	setVisible(g.components.scaleSettings, g.watermarkMode == "anchor" || g.watermarkMode == "tile")
+}
+
+func setVisible(obj fyne.CanvasObject, visible bool) {
+	if visible {
+		obj.Show()
+	} else {
+		obj.Hide()
+	}
+}
+
 func (g *GUI) updateLocale() {
 	locale := locales[g.currentLocale]
 	g.components.languageLabel.SetText(locale.LanguageLabel)
@@ -199,10 +565,59 @@ func (g *GUI) updateLocale() {
 	g.components.watermarkModeLabel.SetText(locale.WatermarkModeLabel)
 	g.components.currentFileLabel.SetText(locale.CurrentFileLabel)
 	g.components.webSizeHintLabel.SetText(locale.WebSizeHint)
+	g.components.workersLabel.SetText(locale.WorkersLabel)
+	g.components.memoryBudgetLabel.SetText(locale.MemoryBudgetLabel)
+	g.components.maxDepthLabel.SetText(locale.MaxDepthLabel)
+	g.components.outputDirLabel.SetText(locale.OutputDirLabel)
+	g.components.nameTemplateLabel.SetText(locale.NameTemplateLabel)
+	g.components.collisionLabel.SetText(locale.CollisionLabel)
+	g.components.resizeModeLabel.SetText(locale.ResizeModeLabel)
+	g.components.cropAnchorLabel.SetText(locale.CropAnchorLabel)
+	g.components.padLabel.SetText(locale.PadLabel)
+	g.components.padEntry.SetPlaceHolder(locale.PadPlaceholder)
+	g.components.renditionsLabel.SetText(locale.RenditionsLabel)
+	g.components.renditionsEntry.SetPlaceHolder(locale.RenditionsPlaceholder)
+	g.components.anchorLabel.SetText(locale.AnchorLabel)
+	g.components.marginLabel.SetText(locale.MarginLabel)
+	g.components.scaleLabel.SetText(locale.ScaleLabel)
+	g.components.tileSpacingLabel.SetText(locale.TileSpacingLabel)
+	g.components.tileOffsetLabel.SetText(locale.TileOffsetLabel)
+	g.components.opacityLabel.SetText(locale.OpacityLabel)
+	g.components.blendModeLabel.SetText(locale.BlendModeLabel)
+	g.components.textLabel.SetText(locale.TextLabel)
+	g.components.fontLabel.SetText(locale.FontLabel)
+	g.components.textSizeLabel.SetText(locale.TextSizeLabel)
+	g.components.textColorLabel.SetText(locale.TextColorLabel)
+	g.components.textEffectLabel.SetText(locale.TextEffectLabel)
+	g.components.textEntry.SetPlaceHolder(locale.TextPlaceholder)
+	g.components.fontButton.SetText(locale.BrowseFontButton)
+	g.components.adaptiveCheck.SetText(locale.AdaptiveCheck)
+	g.components.variantEntry.SetPlaceHolder(locale.VariantPlaceholder)
+	g.components.variantButton.SetText(locale.BrowseButton)
+	g.components.forensicLabel.SetText(locale.ForensicLabel)
+	g.components.forensicEntry.SetPlaceHolder(locale.ForensicPlaceholder)
+	g.components.recipientsLabel.SetText(locale.RecipientsLabel)
+	g.components.recipientsEntry.SetPlaceHolder(locale.RecipientsPlaceholder)
+	g.components.recipientsButton.SetText(locale.BrowseButton)
+	g.components.orientationLabel.SetText(locale.OrientationLabel)
+	g.components.portraitEntry.SetPlaceHolder(locale.PortraitPlaceholder)
+	g.components.landscapeEntry.SetPlaceHolder(locale.LandscapePlaceholder)
+	g.components.squareEntry.SetPlaceHolder(locale.SquarePlaceholder)
+	g.components.portraitButton.SetText(locale.BrowseButton)
+	g.components.landscapeButton.SetText(locale.BrowseButton)
+	g.components.squareButton.SetText(locale.BrowseButton)
+	g.components.tileRotateCheck.SetText(locale.TileRotateCheck)
+	g.components.incrementalCheck.SetText(locale.IncrementalCheck)
+	g.components.pruneCheck.SetText(locale.PruneCheck)
+	g.components.recursiveCheck.SetText(locale.RecursiveCheck)
+	g.components.skipHiddenCheck.SetText(locale.SkipHiddenCheck)
 	g.components.watermarkEntry.SetPlaceHolder(locale.WatermarkPlaceholder)
 	g.components.imageDirEntry.SetPlaceHolder(locale.ImageDirPlaceholder)
 	g.components.fileButton.SetText(locale.BrowseButton)
 	g.components.folderButton.SetText(locale.BrowseFolderButton)
+	g.components.processButton.SetText(locale.ProcessButton)
+	g.components.cancelButton.SetText(locale.CancelButton)
+	g.components.resumeButton.SetText(locale.ResumeButton)
 	g.window.Canvas().Refresh(g.window.Content())
 }
 
@@ -219,7 +634,10 @@ func (g *GUI) createFileButton() *widget.Button {
 				return
 			}
 			g.components.watermarkEntry.SetText(path)
-			if img, err := g.fileHandler.LoadImage(path); err == nil && img != nil {
+			if !g.watermarkCapsSize() {
+				g.components.widthLabel.SetText(locales[g.currentLocale].WidthLabel)
+				g.components.heightLabel.SetText(locales[g.currentLocale].HeightLabel)
+			} else if img, err := g.fileHandler.LoadImage(path); err == nil && img != nil {
 				maxWidth := img.Bounds().Dx()
 				maxHeight := img.Bounds().Dy()
 				g.components.widthLabel.SetText(fmt.Sprintf("%s (100-%d):", locales[g.currentLocale].WidthLabel, maxWidth))
@@ -237,7 +655,29 @@ func (g *GUI) createFileButton() *widget.Button {
 				g.components.heightLabel.SetText(locales[g.currentLocale].HeightLabel)
 			}
 		}, g.window)
-		dialog.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg", ".webp"}))
+		dialog.SetFilter(storage.NewExtensionFileFilter(watermarkExtensions))
+		dialog.Show()
+	})
+}
+
+// watermarkCapsSize reports whether the watermark's pixel size limits the
+// output size, as it does in the full-frame crop and resize modes.
+func (g *GUI) watermarkCapsSize() bool {
+	return g.watermarkMode == "crop" || g.watermarkMode == "resize"
+}
+
+// createBrowseButton fills entry with the path of a file picked from the
+// given extensions.
+func (g *GUI) createBrowseButton(label string, entry *widget.Entry, extensions []string) *widget.Button {
+	return widget.NewButton(label, func() {
+		dialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil || reader == nil {
+				return
+			}
+			reader.Close()
+			entry.SetText(reader.URI().Path())
+		}, g.window)
+		dialog.SetFilter(storage.NewExtensionFileFilter(extensions))
 		dialog.Show()
 	})
 }
@@ -262,83 +702,228 @@ func (g *GUI) createFolderButton() *widget.Button {
 
 func (g *GUI) createProcessButton() *widget.Button {
 	return widget.NewButton(locales[g.currentLocale].ProcessButton, func() {
-		if g.components.watermarkEntry.Text == "" || g.components.imageDirEntry.Text == "" {
+		if (g.components.watermarkEntry.Text == "" && g.cfg.Text == "") || g.components.imageDirEntry.Text == "" {
 			dialog.ShowInformation(locales[g.currentLocale].ErrorTitle, locales[g.currentLocale].NoWatermarkOrFolder, g.window)
 			return
 		}
+		if g.cfg.Text != "" && g.watermarkMode != "anchor" && g.watermarkMode != "tile" {
+			dialog.ShowInformation(locales[g.currentLocale].ErrorTitle, locales[g.currentLocale].TextNeedsPlacement, g.window)
+			return
+		}
 
 		if size, err := strconv.Atoi(g.components.targetSizeEntry.Text); err == nil && size >= 50 && size <= 5000 {
-			fileio.NewImageProcessor().TargetSizeKB = size
+			g.cfg.TargetSizeKB = size
 		} else {
 			g.components.targetSizeEntry.SetText("100")
-			fileio.NewImageProcessor().TargetSizeKB = 100
+			g.cfg.TargetSizeKB = 100
 		}
 
-		g.components.progress.SetValue(0)
-		g.components.imageContainer.Objects = nil
-		g.components.currentFileLabel.SetText(locales[g.currentLocale].CurrentFileLabel)
-		g.window.Canvas().Refresh(g.components.progress)
-		g.window.Canvas().Refresh(g.components.currentFileLabel)
-
-		processor, err := processor.NewImageProcessor(g.components.watermarkEntry.Text, g.cfg, g.fileHandler)
+		// The run gets its own copy so edits made while it is going don't race with the workers.
+		cfg := *g.cfg
+		if spec := strings.TrimSpace(g.components.renditionsEntry.Text); spec != "" {
+			renditions, err := config.ParseRenditions(spec)
+			if err != nil {
+				dialog.ShowInformation(locales[g.currentLocale].ErrorTitle, fmt.Sprintf(locales[g.currentLocale].InvalidRenditions, err), g.window)
+				return
+			}
+			cfg.WithRenditions(renditions...)
+		}
+		if path := g.components.recipientsEntry.Text; path != "" {
+			recipients, err := config.LoadRecipients(path)
+			if err != nil {
+				dialog.ShowInformation(locales[g.currentLocale].ErrorTitle, fmt.Sprintf(locales[g.currentLocale].FailedLoadRecipients, err), g.window)
+				return
+			}
+			cfg.WithRecipients(recipients...)
+		}
+		var proc *processor.ImageProcessor
+		var err error
+		if cfg.Text != "" {
+			proc, err = processor.NewTextProcessor(&cfg, g.fileHandler)
+		} else {
+			proc, err = processor.NewImageProcessor(g.components.watermarkEntry.Text, &cfg, g.fileHandler)
+		}
 		if err != nil {
 			dialog.ShowInformation(locales[g.currentLocale].ErrorTitle, fmt.Sprintf(locales[g.currentLocale].FailedInitProcessor, err), g.window)
 			return
 		}
-		processor.WatermarkMode = g.watermarkMode
-		err = processor.ProcessFolder(g.components.imageDirEntry.Text, g.cfg.OutputFormat, func(current, total int, img *canvas.Image, fileName string) {
-			fyne.Do(func() {
-				if total > 0 {
-					g.components.progress.SetValue(float64(current) / float64(total))
-					if img != nil {
-						g.components.currentFileLabel.SetText(fmt.Sprintf("%s: %s", locales[g.currentLocale].CurrentFileLabel, fileName))
-						fileInfo, err := os.Stat(filepath.Join("Images_watermarked", fileName+"."+g.cfg.OutputFormat))
-						sizeKB := "N/A"
-						if err == nil {
-							sizeKB = fmt.Sprintf("%d KB", fileInfo.Size()/1024)
-						}
-						g.components.imageContainer.Add(container.NewVBox(
-							widget.NewLabel(fmt.Sprintf("%s (%s)", fileName, sizeKB)),
-							img,
-						))
-						g.components.scrollContainer.Refresh()
-					}
-					g.window.Canvas().Refresh(g.components.progress)
-					g.window.Canvas().Refresh(g.components.currentFileLabel)
-				}
-			})
+		proc.WatermarkMode = g.watermarkMode
+
+		imageDir := g.components.imageDirEntry.Text
+		g.startRun(func(ctx context.Context) (*processor.BatchResult, error) {
+			return proc.ProcessFolderContext(ctx, imageDir, cfg.OutputFormat, g.handleProgress)
 		})
+	})
+}
+
+func (g *GUI) createResumeButton() *widget.Button {
+	return widget.NewButton(locales[g.currentLocale].ResumeButton, func() {
+		locale := locales[g.currentLocale]
+		if g.components.imageDirEntry.Text == "" {
+			dialog.ShowInformation(locale.ErrorTitle, locale.NoFolderToResume, g.window)
+			return
+		}
+		outputDir := processor.ResolveOutputDir(g.components.imageDirEntry.Text, g.cfg.OutputDir)
+		journal, err := processor.LoadJournal(outputDir)
 		if err != nil {
-			dialog.ShowInformation(locales[g.currentLocale].ErrorTitle, fmt.Sprintf(locales[g.currentLocale].ProcessingFailed, err), g.window)
-		} else {
-			g.components.currentFileLabel.SetText(locales[g.currentLocale].ProcessingDone)
-			g.window.Canvas().Refresh(g.components.currentFileLabel)
+			dialog.ShowInformation(locale.ErrorTitle, fmt.Sprintf(locale.NothingToResume, err), g.window)
+			return
 		}
+		message := fmt.Sprintf(locale.ResumeConfirm, journal.Started.Format(time.DateTime), len(journal.Done))
+		dialog.ShowConfirm(locale.ResumeButton, message, func(ok bool) {
+			if !ok {
+				return
+			}
+			proc, err := processor.NewResumeProcessor(journal, g.fileHandler)
+			if err != nil {
+				dialog.ShowInformation(locale.ErrorTitle, fmt.Sprintf(locale.FailedInitProcessor, err), g.window)
+				return
+			}
+			g.startRun(func(ctx context.Context) (*processor.BatchResult, error) {
+				return proc.Resume(ctx, journal, g.handleProgress)
+			})
+		}, g.window)
 	})
 }
 
-func SetupGUI(window fyne.Window) {
-	cfg := config.JpgConfig()
-	fileHandler := &fileioHandler{}
-	gui := NewGUI(window, cfg, fileHandler)
-	window.SetIcon(Icon())
-	gui.Setup()
+// startRun resets the progress display and runs process in the background
+// until it finishes or the Cancel button is pressed.
+func (g *GUI) startRun(process func(ctx context.Context) (*processor.BatchResult, error)) {
+	g.components.progress.SetValue(0)
+	g.components.imageContainer.Objects = nil
+	g.components.currentFileLabel.SetText(locales[g.currentLocale].CurrentFileLabel)
+	g.window.Canvas().Refresh(g.components.progress)
+	g.window.Canvas().Refresh(g.components.currentFileLabel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+	g.components.processButton.Disable()
+	g.components.resumeButton.Disable()
+	g.components.cancelButton.Enable()
+	go func() {
+		result, err := process(ctx)
+		cancel()
+		fyne.Do(func() {
+			g.finishProcessing(result, err)
+		})
+	}()
+}
+
+func (g *GUI) createCancelButton() *widget.Button {
+	button := widget.NewButton(locales[g.currentLocale].CancelButton, func() {
+		if g.cancel != nil {
+			g.cancel()
+		}
+	})
+	button.Disable()
+	return button
+}
+
+func (g *GUI) finishProcessing(result *processor.BatchResult, err error) {
+	locale := locales[g.currentLocale]
+	g.cancel = nil
+	g.components.processButton.Enable()
+	g.components.resumeButton.Enable()
+	g.components.cancelButton.Disable()
+	switch {
+	case result == nil:
+		dialog.ShowInformation(locale.ErrorTitle, fmt.Sprintf(locale.ProcessingFailed, err), g.window)
+	case errors.Is(err, context.Canceled):
+		g.components.currentFileLabel.SetText(locale.ProcessingCancelled)
+		dialog.ShowInformation(locale.CancelButton, g.summarize(result), g.window)
+	case result.Count(processor.StatusFailed) > 0:
+		g.components.currentFileLabel.SetText(locale.ProcessingFinishedWithErrors)
+		dialog.ShowInformation(locale.ErrorTitle, g.summarize(result), g.window)
+	default:
+		g.components.currentFileLabel.SetText(locale.ProcessingDone)
+		if len(result.Collisions()) > 0 {
+			dialog.ShowInformation(locale.ProcessingDone, g.summarize(result), g.window)
+		}
+	}
+	g.window.Canvas().Refresh(g.components.currentFileLabel)
 }
 
-type fileioHandler struct{}
+// summarize renders the batch counts followed by the first few failures.
+func (g *GUI) summarize(result *processor.BatchResult) string {
+	const maxListed = 10
+	locale := locales[g.currentLocale]
+	var b strings.Builder
+	fmt.Fprintf(&b, locale.BatchSummary, result.Count(processor.StatusOK), result.Count(processor.StatusSkipped), result.Count(processor.StatusFailed))
+	if collisions := result.Collisions(); len(collisions) > 0 {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, locale.CollisionSummary, len(collisions))
+	}
+	for i, f := range result.Failed() {
+		if i == maxListed {
+			b.WriteString("\n...")
+			break
+		}
+		fmt.Fprintf(&b, "\n%s: %v", filepath.Base(f.InputPath), f.Err)
+	}
+	return b.String()
+}
 
-func (f *fileioHandler) LoadImage(path string) (image.Image, error) {
-	return fileio.LoadImage(path)
+func (g *GUI) handleProgress(ev processor.Event) {
+	if ev.Type == processor.FileFinished {
+		// Workers wait for the callback, so the output is decoded elsewhere.
+		go g.addPreview(ev)
+	}
+	fyne.Do(func() {
+		if ev.Total > 0 {
+			g.components.progress.SetValue(float64(ev.Current) / float64(ev.Total))
+		}
+		switch ev.Type {
+		case processor.FileStarted:
+			g.components.currentFileLabel.SetText(fmt.Sprintf("%s: %s", locales[g.currentLocale].CurrentFileLabel, ev.FileName))
+		case processor.FileFailed:
+			g.components.imageContainer.Add(widget.NewLabel(fmt.Sprintf("%s: %v", ev.FileName, ev.Err)))
+			g.components.scrollContainer.Refresh()
+		case processor.FileSkipped:
+			g.components.imageContainer.Add(widget.NewLabel(fmt.Sprintf("%s: %s", ev.FileName, locales[g.currentLocale].SkippedLabel)))
+			g.components.scrollContainer.Refresh()
+		}
+		g.window.Canvas().Refresh(g.components.progress)
+		g.window.Canvas().Refresh(g.components.currentFileLabel)
+	})
 }
 
-func (f *fileioHandler) SaveImage(img image.Image, path, format string, cfg *config.Config) error {
-	return fileio.NewImageProcessor().SaveImage(img, path, format, cfg)
+// addPreview adds the output of a finished file to the result list.
+func (g *GUI) addPreview(ev processor.Event) {
+	g.previewMu.Lock()
+	preview := loadPreview(ev.OutputPath)
+	g.previewMu.Unlock()
+	if preview == nil {
+		return
+	}
+	fyne.Do(func() {
+		g.components.imageContainer.Add(container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("%s (%d KB, %dx%d, q%d)", filepath.Base(ev.OutputPath), ev.SizeBytes/1024, ev.Width, ev.Height, ev.Quality)),
+			preview,
+		))
+		g.components.scrollContainer.Refresh()
+	})
 }
 
-func (f *fileioHandler) CreateDir(path string) error {
-	return fileio.CreateDir(path)
+// loadPreview decodes a written output file into a thumbnail for the result list.
+func loadPreview(path string) *canvas.Image {
+	if path == "" {
+		return nil
+	}
+	img, err := fileio.LoadImage(path)
+	if err != nil {
+		fmt.Printf("Error decoding image for UI: %v\n", err)
+		return nil
+	}
+	canvasImg := canvas.NewImageFromImage(img)
+	canvasImg.FillMode = canvas.ImageFillContain
+	canvasImg.SetMinSize(fyne.NewSize(200, 200))
+	return canvasImg
 }
 
-func (f *fileioHandler) ReadDir(path string) ([]os.DirEntry, error) {
-	return fileio.ReadDir(path)
+func SetupGUI(window fyne.Window) {
+	cfg := config.JpgConfig()
+	fileHandler := &fileio.Handler{}
+	gui := NewGUI(window, cfg, fileHandler)
+	window.SetIcon(Icon())
+	gui.Setup()
 }
@@ -1,92 +1,254 @@
 package gui
 
 type Locale struct {
-	LanguageLabel          string
-	WatermarkLabel         string
-	ImageDirLabel          string
-	FormatLabel            string
-	QualityLabel           string
-	WebSizeHint            string
-	WidthLabel             string
-	HeightLabel            string
-	TargetSizeLabel        string
-	WatermarkModeLabel     string
-	WatermarkPlaceholder   string
-	ImageDirPlaceholder    string
-	ProcessButton          string
-	CurrentFileLabel       string
-	ProcessingDone         string
-	BrowseButton           string
-	BrowseFolderButton     string
-	ErrorTitle             string
-	NoWatermarkOrFolder    string
-	FailedSelectWatermark  string
-	InvalidFile            string
-	FailedSelectFolder     string
-	InvalidFolder          string
-	FailedInitProcessor    string
-	ProcessingFailed       string
-	WidthExceedsWatermark  string
-	HeightExceedsWatermark string
+	LanguageLabel                string
+	WatermarkLabel               string
+	ImageDirLabel                string
+	FormatLabel                  string
+	QualityLabel                 string
+	WebSizeHint                  string
+	WidthLabel                   string
+	HeightLabel                  string
+	TargetSizeLabel              string
+	WatermarkModeLabel           string
+	WorkersLabel                 string
+	MemoryBudgetLabel            string
+	WatermarkPlaceholder         string
+	ImageDirPlaceholder          string
+	RecursiveCheck               string
+	SkipHiddenCheck              string
+	MaxDepthLabel                string
+	OutputDirLabel               string
+	NameTemplateLabel            string
+	CollisionLabel               string
+	ResizeModeLabel              string
+	CropAnchorLabel              string
+	PadLabel                     string
+	PadPlaceholder               string
+	RenditionsLabel              string
+	RenditionsPlaceholder        string
+	AnchorLabel                  string
+	MarginLabel                  string
+	ScaleLabel                   string
+	TileSpacingLabel             string
+	TileOffsetLabel              string
+	TileRotateCheck              string
+	OpacityLabel                 string
+	BlendModeLabel               string
+	AdaptiveCheck                string
+	OrientationLabel             string
+	PortraitPlaceholder          string
+	LandscapePlaceholder         string
+	SquarePlaceholder            string
+	VariantPlaceholder           string
+	ForensicLabel                string
+	ForensicPlaceholder          string
+	RecipientsLabel              string
+	RecipientsPlaceholder        string
+	TextLabel                    string
+	TextPlaceholder              string
+	FontLabel                    string
+	BrowseFontButton             string
+	TextSizeLabel                string
+	TextColorLabel               string
+	TextEffectLabel              string
+	TextNeedsPlacement           string
+	IncrementalCheck             string
+	PruneCheck                   string
+	ProcessButton                string
+	CurrentFileLabel             string
+	ProcessingDone               string
+	ProcessingCancelled          string
+	ProcessingFinishedWithErrors string
+	BatchSummary                 string
+	CollisionSummary             string
+	SkippedLabel                 string
+	CancelButton                 string
+	ResumeButton                 string
+	NoFolderToResume             string
+	NothingToResume              string
+	ResumeConfirm                string
+	BrowseButton                 string
+	BrowseFolderButton           string
+	ErrorTitle                   string
+	NoWatermarkOrFolder          string
+	FailedSelectWatermark        string
+	InvalidFile                  string
+	FailedSelectFolder           string
+	InvalidFolder                string
+	FailedInitProcessor          string
+	FailedLoadRecipients         string
+	InvalidRenditions            string
+	ProcessingFailed             string
+	WidthExceedsWatermark        string
+	HeightExceedsWatermark       string
 }
 
 var locales = map[string]Locale{
 	"en": {
-		LanguageLabel:          "Language:",
-		WatermarkLabel:         "Watermark file:",
-		ImageDirLabel:          "Image folder:",
-		FormatLabel:            "Output format:",
-		QualityLabel:           "Quality (1-100):",
-		WebSizeHint:            "Note: For web, target size ≤100 KB is optimal",
-		WidthLabel:             "Max Width (100-4096):",
-		HeightLabel:            "Max Height (100-4096):",
-		TargetSizeLabel:        "Target Size (KB, 50-5000):",
-		WatermarkModeLabel:     "Watermark Mode:",
-		WatermarkPlaceholder:   "Select watermark.png",
-		ImageDirPlaceholder:    "Select image folder",
-		ProcessButton:          "Process",
-		CurrentFileLabel:       "Processing: None",
-		ProcessingDone:         "Processing: Done",
-		BrowseButton:           "Browse...",
-		BrowseFolderButton:     "Browse Folder...",
-		ErrorTitle:             "Error",
-		NoWatermarkOrFolder:    "Please select a watermark file and image folder!",
-		FailedSelectWatermark:  "Failed to select watermark file!",
-		InvalidFile:            "Invalid file: %v",
-		FailedSelectFolder:     "Failed to select folder!",
-		InvalidFolder:          "Invalid folder: %v",
-		FailedInitProcessor:    "Failed to initialize processor: %v",
-		ProcessingFailed:       "Processing failed: %v",
-		WidthExceedsWatermark:  "Width exceeds watermark width (%d px)",
-		HeightExceedsWatermark: "Height exceeds watermark height (%d px)",
+		LanguageLabel:                "Language:",
+		WatermarkLabel:               "Watermark file:",
+		ImageDirLabel:                "Image folder:",
+		FormatLabel:                  "Output format:",
+		QualityLabel:                 "Quality (1-100):",
+		WebSizeHint:                  "Note: For web, target size ≤100 KB is optimal",
+		WidthLabel:                   "Max Width (100-4096):",
+		HeightLabel:                  "Max Height (100-4096):",
+		TargetSizeLabel:              "Target Size (KB, 50-5000):",
+		WatermarkModeLabel:           "Watermark Mode:",
+		WorkersLabel:                 "Parallel workers:",
+		MemoryBudgetLabel:            "Memory budget (MB, 0 = no limit):",
+		WatermarkPlaceholder:         "Select watermark.png",
+		ImageDirPlaceholder:          "Select image folder",
+		RecursiveCheck:               "Include subfolders",
+		SkipHiddenCheck:              "Skip hidden folders",
+		MaxDepthLabel:                "Max folder depth (0 = no limit):",
+		OutputDirLabel:               "Output folder (absolute or relative to image folder):",
+		NameTemplateLabel:            "File name template ({name} {ext} {width} {height} {quality} {index} {date}):",
+		CollisionLabel:               "If output file exists:",
+		ResizeModeLabel:              "Resize:",
+		CropAnchorLabel:              "Crop (fill):",
+		PadLabel:                     "Padding (pad):",
+		PadPlaceholder:               "#RRGGBB or blur",
+		RenditionsLabel:              "Renditions (optional):",
+		RenditionsPlaceholder:        "e.g. 320:30, 640:60, 1024, 1920:250:90 (width:KB:max quality)",
+		AnchorLabel:                  "Watermark position:",
+		MarginLabel:                  "Margin (px or %):",
+		ScaleLabel:                   "Size (% of shorter side):",
+		TileSpacingLabel:             "Tile spacing (px):",
+		TileOffsetLabel:              "Grid offset X, Y (px):",
+		TileRotateCheck:              "Rotate pattern 45°",
+		OpacityLabel:                 "Watermark opacity (%):",
+		BlendModeLabel:               "Blend mode:",
+		AdaptiveCheck:                "Adapt to image brightness",
+		OrientationLabel:             "Watermarks by orientation (optional):",
+		PortraitPlaceholder:          "Portrait images",
+		LandscapePlaceholder:         "Landscape images",
+		SquarePlaceholder:            "Square images",
+		VariantPlaceholder:           "Second watermark variant (empty = inverted colors)",
+		ForensicLabel:                "Invisible watermark owner ID (optional):",
+		ForensicPlaceholder:          "e.g. acme-studio, read back with goimgtool-cli verify",
+		RecipientsLabel:              "Recipient list (optional):",
+		RecipientsPlaceholder:        "CSV with id and name columns: one copy per recipient",
+		TextLabel:                    "Or watermark text:",
+		TextPlaceholder:              "© Our Brand 2026",
+		FontLabel:                    "Font file (TTF/OTF, empty for built-in):",
+		BrowseFontButton:             "Browse Font",
+		TextSizeLabel:                "Text size (%):",
+		TextColorLabel:               "Text color:",
+		TextEffectLabel:              "Text effect:",
+		TextNeedsPlacement:           "Text watermarks need the anchor or tile mode.",
+		IncrementalCheck:             "Skip unchanged images",
+		PruneCheck:                   "Remove outputs of deleted images",
+		ProcessButton:                "Process",
+		CurrentFileLabel:             "Processing: None",
+		ProcessingDone:               "Processing: Done",
+		ProcessingCancelled:          "Processing: Cancelled",
+		ProcessingFinishedWithErrors: "Processing: Finished with errors",
+		BatchSummary:                 "%d processed, %d skipped, %d failed",
+		CollisionSummary:             "%d output name collisions",
+		SkippedLabel:                 "skipped",
+		CancelButton:                 "Cancel",
+		ResumeButton:                 "Resume",
+		NoFolderToResume:             "Please select the image folder of the interrupted run!",
+		NothingToResume:              "Nothing to resume: %v",
+		ResumeConfirm:                "Resume the run started %s? %d files are already done.",
+		BrowseButton:                 "Browse...",
+		BrowseFolderButton:           "Browse Folder...",
+		ErrorTitle:                   "Error",
+		NoWatermarkOrFolder:          "Please select a watermark file or enter a text, and an image folder!",
+		FailedSelectWatermark:        "Failed to select watermark file!",
+		InvalidFile:                  "Invalid file: %v",
+		FailedSelectFolder:           "Failed to select folder!",
+		InvalidFolder:                "Invalid folder: %v",
+		FailedInitProcessor:          "Failed to initialize processor: %v",
+		FailedLoadRecipients:         "Failed to load recipient list: %v",
+		InvalidRenditions:            "Invalid renditions: %v",
+		ProcessingFailed:             "Processing failed: %v",
+		WidthExceedsWatermark:        "Width exceeds watermark width (%d px)",
+		HeightExceedsWatermark:       "Height exceeds watermark height (%d px)",
 	},
 	"ru": {
-		LanguageLabel:          "Язык:",
-		WatermarkLabel:         "Файл водяного знака:",
-		ImageDirLabel:          "Папка с изображениями:",
-		FormatLabel:            "Формат вывода:",
-		QualityLabel:           "Качество (1-100):",
-		WebSizeHint:            "Примечание: Для веба оптимальный размер ≤100 КБ",
-		WidthLabel:             "Макс. ширина (100-4096):",
-		HeightLabel:            "Макс. высота (100-4096):",
-		TargetSizeLabel:        "Целевой размер (КБ, 50-5000):",
-		WatermarkModeLabel:     "Режим водяного знака:",
-		WatermarkPlaceholder:   "Выберите watermark.png",
-		ImageDirPlaceholder:    "Выберите папку с изображениями",
-		ProcessButton:          "Обработать",
-		CurrentFileLabel:       "Обработка: Нет",
-		ProcessingDone:         "Обработка: Завершено",
-		BrowseButton:           "Выбрать watermark...",
-		BrowseFolderButton:     "Выбрать папку...",
-		ErrorTitle:             "Ошибка",
-		NoWatermarkOrFolder:    "Пожалуйста, выберите файл водяного знака и папку с изображениями!",
-		FailedSelectWatermark:  "Не удалось выбрать файл водяного знака!",
-		InvalidFile:            "Недопустимый файл: %v",
-		FailedSelectFolder:     "Не удалось выбрать папку!",
-		InvalidFolder:          "Недопустимая папка: %v",
-		FailedInitProcessor:    "Не удалось инициализировать процессор: %v",
-		ProcessingFailed:       "Ошибка обработки: %v",
-		WidthExceedsWatermark:  "Ширина превышает ширину водяного знака (%d пикс.)",
-		HeightExceedsWatermark: "Высота превышает высоту водяного знака (%d пикс.)",
+		LanguageLabel:                "Язык:",
+		WatermarkLabel:               "Файл водяного знака:",
+		ImageDirLabel:                "Папка с изображениями:",
+		FormatLabel:                  "Формат вывода:",
+		QualityLabel:                 "Качество (1-100):",
+		WebSizeHint:                  "Примечание: Для веба оптимальный размер ≤100 КБ",
+		WidthLabel:                   "Макс. ширина (100-4096):",
+		HeightLabel:                  "Макс. высота (100-4096):",
+		TargetSizeLabel:              "Целевой размер (КБ, 50-5000):",
+		WatermarkModeLabel:           "Режим водяного знака:",
+		WorkersLabel:                 "Параллельных потоков:",
+		MemoryBudgetLabel:            "Лимит памяти (МБ, 0 = без лимита):",
+		WatermarkPlaceholder:         "Выберите watermark.png",
+		ImageDirPlaceholder:          "Выберите папку с изображениями",
+		RecursiveCheck:               "Включая подпапки",
+		SkipHiddenCheck:              "Пропускать скрытые папки",
+		MaxDepthLabel:                "Макс. глубина папок (0 = без ограничений):",
+		OutputDirLabel:               "Папка вывода (абсолютный путь или относительно папки с изображениями):",
+		NameTemplateLabel:            "Шаблон имени ({name} {ext} {width} {height} {quality} {index} {date}):",
+		CollisionLabel:               "Если файл уже существует:",
+		ResizeModeLabel:              "Изменение размера:",
+		CropAnchorLabel:              "Обрезка (fill):",
+		PadLabel:                     "Поля (pad):",
+		PadPlaceholder:               "#RRGGBB или blur",
+		RenditionsLabel:              "Варианты размеров (необязательно):",
+		RenditionsPlaceholder:        "например, 320:30, 640:60, 1024, 1920:250:90 (ширина:КБ:макс. качество)",
+		AnchorLabel:                  "Положение водяного знака:",
+		MarginLabel:                  "Отступ (px или %):",
+		ScaleLabel:                   "Размер (% от меньшей стороны):",
+		TileSpacingLabel:             "Промежуток между плитками (px):",
+		TileOffsetLabel:              "Смещение сетки X, Y (px):",
+		TileRotateCheck:              "Повернуть узор на 45°",
+		OpacityLabel:                 "Непрозрачность водяного знака (%):",
+		BlendModeLabel:               "Режим наложения:",
+		AdaptiveCheck:                "Подстраивать под яркость изображения",
+		OrientationLabel:             "Водяные знаки по ориентации (необязательно):",
+		PortraitPlaceholder:          "Вертикальные изображения",
+		LandscapePlaceholder:         "Горизонтальные изображения",
+		SquarePlaceholder:            "Квадратные изображения",
+		VariantPlaceholder:           "Второй вариант водяного знака (пусто — инвертированные цвета)",
+		ForensicLabel:                "ID владельца для невидимого водяного знака (необязательно):",
+		ForensicPlaceholder:          "например, acme-studio; проверка: goimgtool-cli verify",
+		RecipientsLabel:              "Список получателей (необязательно):",
+		RecipientsPlaceholder:        "CSV со столбцами id и name: отдельная копия для каждого получателя",
+		TextLabel:                    "Или текст водяного знака:",
+		TextPlaceholder:              "© Наш бренд 2026",
+		FontLabel:                    "Файл шрифта (TTF/OTF, пусто — встроенный):",
+		BrowseFontButton:             "Выбрать шрифт",
+		TextSizeLabel:                "Размер текста (%):",
+		TextColorLabel:               "Цвет текста:",
+		TextEffectLabel:              "Эффект текста:",
+		TextNeedsPlacement:           "Для текстового водяного знака нужен режим anchor или tile.",
+		IncrementalCheck:             "Пропускать неизменённые",
+		PruneCheck:                   "Удалять результаты удалённых",
+		ProcessButton:                "Обработать",
+		CurrentFileLabel:             "Обработка: Нет",
+		ProcessingDone:               "Обработка: Завершено",
+		ProcessingCancelled:          "Обработка: Отменено",
+		ProcessingFinishedWithErrors: "Обработка: Завершено с ошибками",
+		BatchSummary:                 "Обработано: %d, пропущено: %d, с ошибками: %d",
+		CollisionSummary:             "Совпадений имён файлов: %d",
+		SkippedLabel:                 "пропущен",
+		CancelButton:                 "Отмена",
+		ResumeButton:                 "Продолжить",
+		NoFolderToResume:             "Выберите папку с изображениями прерванной обработки!",
+		NothingToResume:              "Нечего продолжать: %v",
+		ResumeConfirm:                "Продолжить обработку, начатую %s? Уже готово файлов: %d.",
+		BrowseButton:                 "Выбрать watermark...",
+		BrowseFolderButton:           "Выбрать папку...",
+		ErrorTitle:                   "Ошибка",
+		NoWatermarkOrFolder:          "Пожалуйста, выберите файл водяного знака или введите текст, а также папку с изображениями!",
+		FailedSelectWatermark:        "Не удалось выбрать файл водяного знака!",
+		InvalidFile:                  "Недопустимый файл: %v",
+		FailedSelectFolder:           "Не удалось выбрать папку!",
+		InvalidFolder:                "Недопустимая папка: %v",
+		FailedInitProcessor:          "Не удалось инициализировать процессор: %v",
+		FailedLoadRecipients:         "Не удалось загрузить список получателей: %v",
+		InvalidRenditions:            "Неверные варианты размеров: %v",
+		ProcessingFailed:             "Ошибка обработки: %v",
+		WidthExceedsWatermark:        "Ширина превышает ширину водяного знака (%d пикс.)",
+		HeightExceedsWatermark:       "Высота превышает высоту водяного знака (%d пикс.)",
 	},
 }
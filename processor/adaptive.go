@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// loadAdaptiveVariant loads Config.AdaptiveVariant, the second watermark
+// that the adaptive option can switch to.
+func (p *ImageProcessor) loadAdaptiveVariant() error {
+	if !p.Config.Adaptive || p.Config.AdaptiveVariant == "" {
+		return nil
+	}
+	variant, err := newImageProcessor(p.Config.AdaptiveVariant, p.Config, p.FileHandler)
+	if err != nil {
+		return fmt.Errorf("error loading watermark variant: %v", err)
+	}
+	p.variant = variant
+	return nil
+}
+
+// adaptWatermark measures the luminance of img under the watermark and
+// returns whichever of the watermark and its variant stands out more.
+func (p *ImageProcessor) adaptWatermark(img image.Image, pl placement) (placement, error) {
+	background, ok := backgroundLuminance(img, pl.watermark, pl.at)
+	if !ok {
+		return pl, nil
+	}
+	alt := placement{watermark: invertColors(pl.watermark), at: pl.at, anchor: pl.anchor}
+	if p.variant != nil {
+		var err error
+		if alt, err = p.variant.placeWatermark(img, p.WatermarkMode); err != nil {
+			return placement{}, err
+		}
+	}
+	own, _ := meanLuminance(pl.watermark)
+	other, _ := meanLuminance(alt.watermark)
+	if math.Abs(other-background) > math.Abs(own-background) {
+		return alt, nil
+	}
+	return pl, nil
+}
+
+// backgroundLuminance averages the luminance of img inside at, weighting
+// every pixel by the alpha of the watermark drawn over it. It reports false
+// when the watermark covers nothing.
+func backgroundLuminance(img image.Image, watermark image.Image, at image.Rectangle) (float64, bool) {
+	offset := watermark.Bounds().Min.Sub(at.Min)
+	area := at.Intersect(img.Bounds())
+	var sum, weight float64
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			_, _, _, a := watermark.At(x+offset.X, y+offset.Y).RGBA()
+			if a == 0 {
+				continue
+			}
+			r, g, b, _ := img.At(x, y).RGBA()
+			w := float64(a) / 0xffff
+			sum += w * luminance(r, g, b)
+			weight += w
+		}
+	}
+	if weight == 0 {
+		return 0, false
+	}
+	return sum / weight, true
+}
+
+// meanLuminance averages the luminance of the visible pixels of img.
+func meanLuminance(img image.Image) (float64, bool) {
+	b := img.Bounds()
+	var sum, weight float64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			w := float64(c.A) / 255
+			sum += w * luminance(uint32(c.R)*0x101, uint32(c.G)*0x101, uint32(c.B)*0x101)
+			weight += w
+		}
+	}
+	if weight == 0 {
+		return 0, false
+	}
+	return sum / weight, true
+}
+
+// luminance returns the Rec. 709 luma of 16-bit color channels in [0, 1].
+func luminance(r, g, b uint32) float64 {
+	return (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / 0xffff
+}
+
+// invertColors returns a copy of img with inverted colors and the same alpha.
+func invertColors(img image.Image) *image.NRGBA {
+	out := image.NewNRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+	for i := 0; i < len(out.Pix); i += 4 {
+		out.Pix[i] = 255 - out.Pix[i]
+		out.Pix[i+1] = 255 - out.Pix[i+1]
+		out.Pix[i+2] = 255 - out.Pix[i+2]
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/del1x/GoIMGtool/config"
+)
+
+// anchorAlign maps an anchor to its horizontal and vertical alignment:
+// 0 is left/top, 1 is center and 2 is right/bottom.
+var anchorAlign = map[string][2]int{
+	config.AnchorTopLeft:     {0, 0},
+	config.AnchorTop:         {1, 0},
+	config.AnchorTopRight:    {2, 0},
+	config.AnchorLeft:        {0, 1},
+	config.AnchorCenter:      {1, 1},
+	config.AnchorRight:       {2, 1},
+	config.AnchorBottomLeft:  {0, 2},
+	config.AnchorBottom:      {1, 2},
+	config.AnchorBottomRight: {2, 2},
+}
+
+func validateAnchor(cfg *config.Config) error {
+	if _, ok := anchorAlign[cfg.Anchor]; !ok && cfg.Anchor != config.AnchorAuto {
+		return fmt.Errorf("unknown watermark anchor %q", cfg.Anchor)
+	}
+	if cfg.MarginUnit != config.MarginPixels && cfg.MarginUnit != config.MarginPercent {
+		return fmt.Errorf("unknown margin unit %q", cfg.MarginUnit)
+	}
+	if cfg.Margin < 0 {
+		return fmt.Errorf("watermark margin must not be negative")
+	}
+	return validateScale(cfg)
+}
+
+func validateScale(cfg *config.Config) error {
+	if cfg.Scale < 0 || cfg.Scale > 100 {
+		return fmt.Errorf("watermark scale must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// scaledWatermark returns the watermark sized by Config.Scale for an image
+// with the given bounds, never larger than the image itself.
+func (p *ImageProcessor) scaledWatermark(bounds image.Rectangle) image.Image {
+	shorter := float64(min(bounds.Dx(), bounds.Dy()))
+	wm := p.Watermark
+	w, h := wm.Bounds().Dx(), wm.Bounds().Dy()
+
+	factor := 1.0
+	if p.Config.Scale > 0 {
+		factor = shorter * p.Config.Scale / 100 / float64(max(w, h))
+	}
+	factor = math.Min(factor, math.Min(float64(bounds.Dx())/float64(w), float64(bounds.Dy())/float64(h)))
+	sw := max(1, int(math.Round(float64(w)*factor)))
+	sh := max(1, int(math.Round(float64(h)*factor)))
+	if sw == w && sh == h {
+		return wm
+	}
+	return p.resizedWatermark(sw, sh)
+}
+
+// watermarkFor returns the watermark to place on an image with the given
+// bounds: the rendered text for text watermarks, the scaled image otherwise.
+func (p *ImageProcessor) watermarkFor(bounds image.Rectangle) (image.Image, error) {
+	if p.Font != nil {
+		return p.renderText(bounds)
+	}
+	return p.scaledWatermark(bounds), nil
+}
+
+// anchorWatermark sizes the watermark for img and places it at the
+// configured anchor, or at the least busy one for AnchorAuto.
+func (p *ImageProcessor) anchorWatermark(img image.Image) (placement, error) {
+	cfg := p.Config
+	bounds := img.Bounds()
+	shorter := float64(min(bounds.Dx(), bounds.Dy()))
+	wm, err := p.watermarkFor(bounds)
+	if err != nil {
+		return placement{}, err
+	}
+	size := wm.Bounds().Size()
+
+	margin := cfg.Margin
+	if cfg.MarginUnit == config.MarginPercent {
+		margin = shorter * cfg.Margin / 100
+	}
+	m := int(math.Round(margin))
+	anchor := cfg.Anchor
+	if anchor == config.AnchorAuto {
+		anchor = quietestAnchor(img, size, m)
+	}
+	return placement{watermark: wm, at: anchorRect(anchor, bounds, size, m), anchor: anchor}, nil
+}
+
+// anchorRect is where a watermark of the given size goes inside bounds when
+// placed at anchor with margin pixels to the edges.
+func anchorRect(anchor string, bounds image.Rectangle, size image.Point, margin int) image.Rectangle {
+	align := anchorAlign[anchor]
+	x := alignOffset(align[0], bounds.Dx(), size.X, margin)
+	y := alignOffset(align[1], bounds.Dy(), size.Y, margin)
+	return image.Rect(x, y, x+size.X, y+size.Y).Add(bounds.Min)
+}
+
+// alignOffset positions a span of length size inside total, keeping margin
+// from the edge it is aligned to. The margin shrinks when it doesn't fit.
+func alignOffset(align, total, size, margin int) int {
+	margin = max(0, min(margin, (total-size)/2))
+	switch align {
+	case 0:
+		return margin
+	case 1:
+		return (total - size) / 2
+	default:
+		return total - size - margin
+	}
+}
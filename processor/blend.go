@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"slices"
+
+	"github.com/del1x/GoIMGtool/config"
+)
+
+// blendFuncs mix a backdrop channel b with a watermark channel s, both in
+// [0, 1], following the W3C compositing definitions.
+var blendFuncs = map[string]func(b, s float64) float64{
+	config.BlendMultiply: func(b, s float64) float64 { return b * s },
+	config.BlendScreen:   func(b, s float64) float64 { return b + s - b*s },
+	config.BlendOverlay: func(b, s float64) float64 {
+		if b <= 0.5 {
+			return 2 * b * s
+		}
+		return 1 - 2*(1-b)*(1-s)
+	},
+	config.BlendSoftLight: func(b, s float64) float64 {
+		if s <= 0.5 {
+			return b - (1-2*s)*b*(1-b)
+		}
+		d := math.Sqrt(b)
+		if b <= 0.25 {
+			d = ((16*b-12)*b + 4) * b
+		}
+		return b + (2*s-1)*(d-b)
+	},
+	config.BlendDifference: func(b, s float64) float64 { return math.Abs(b - s) },
+}
+
+func validateBlendMode(mode string) error {
+	if mode != "" && !slices.Contains(config.BlendModes, mode) {
+		return fmt.Errorf("unknown blend mode %q", mode)
+	}
+	return nil
+}
+
+// blend composites watermark onto dst inside the rectangle at, mixing the
+// colors with the given blend mode and scaling the watermark alpha by
+// opacity. The result is then laid over dst like normal alpha compositing.
+func blend(dst *image.NRGBA, at image.Rectangle, watermark image.Image, mode string, opacity float64) {
+	mix := blendFuncs[mode]
+	src, ok := watermark.(*image.NRGBA)
+	if !ok {
+		src = image.NewNRGBA(watermark.Bounds())
+		draw.Draw(src, src.Bounds(), watermark, watermark.Bounds().Min, draw.Src)
+	}
+	offset := src.Bounds().Min.Sub(at.Min)
+	area := at.Intersect(dst.Bounds())
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			s := src.NRGBAAt(x+offset.X, y+offset.Y)
+			as := float64(s.A) / 255 * opacity
+			if as == 0 {
+				continue
+			}
+			i := dst.PixOffset(x, y)
+			d := dst.Pix[i : i+4 : i+4]
+			ab := float64(d[3]) / 255
+			ao := as + ab*(1-as)
+			for c, sc := range [3]uint8{s.R, s.G, s.B} {
+				cb := float64(d[c]) / 255
+				cs := float64(sc) / 255
+				// Where the backdrop is transparent the watermark shows as is.
+				mixed := (1-ab)*cs + ab*mix(cb, cs)
+				co := (as*mixed + (1-as)*ab*cb) / ao
+				d[c] = uint8(math.Round(co * 255))
+			}
+			d[3] = uint8(math.Round(ao * 255))
+		}
+	}
+}
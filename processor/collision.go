@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/del1x/GoIMGtool/config"
+)
+
+// ErrOutputExists is reported under the "fail" collision policy.
+var ErrOutputExists = errors.New("output file already exists")
+
+// skipError marks a file that was deliberately not written.
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+// claimOutput reserves path for inputPath, applying Config.Collision when
+// the path already exists on disk or was claimed earlier in the run. It
+// returns the path to write to and records any collision in res.
+func (p *ImageProcessor) claimOutput(r *run, path, inputPath string, res *FileResult) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owner, claimed := r.claimed[path]
+	if !claimed && !p.FileHandler.Exists(path) {
+		r.claimed[path] = inputPath
+		return path, nil
+	}
+	if claimed {
+		res.CollidedWith = owner
+	} else {
+		res.CollidedWith = path
+	}
+
+	switch p.Config.Collision {
+	case config.CollisionSkip:
+		return "", &skipError{reason: "output exists: " + path}
+	case config.CollisionFail:
+		return "", &FileError{Op: "save", Path: path, Err: ErrOutputExists}
+	case config.CollisionSuffix:
+		ext := filepath.Ext(path)
+		base := strings.TrimSuffix(path, ext)
+		for n := 1; ; n++ {
+			candidate := fmt.Sprintf("%s_%d%s", base, n, ext)
+			if _, taken := r.claimed[candidate]; taken || p.FileHandler.Exists(candidate) {
+				continue
+			}
+			r.claimed[candidate] = inputPath
+			fmt.Printf("Output %s exists, writing %s instead\n", path, candidate)
+			return candidate, nil
+		}
+	default:
+		fmt.Printf("Overwriting %s\n", path)
+		r.claimed[path] = inputPath
+		return path, nil
+	}
+}
+
+// releaseOutput gives up the claim of inputPath on path, after its output
+// could not be written there.
+func (r *run) releaseOutput(path, inputPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.claimed[path] == inputPath {
+		delete(r.claimed, path)
+	}
+}
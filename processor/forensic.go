@@ -0,0 +1,269 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/del1x/GoIMGtool/config"
+)
+
+// The invisible watermark hides a 32-byte payload in the luma of 8x8 pixel
+// blocks, one bit per block, by quantization index modulation of two
+// mid-frequency DCT coefficients. JPEG and WebP keep those coefficients
+// well within a quantizer step at typical qualities. The payload repeats in
+// tiles of markTile x markTile blocks, and extraction adds up the soft
+// decisions of every copy, so compression noise and local clipping average
+// out. Because the tiles are cyclic, a cropped image still decodes: the
+// extractor tries every block alignment and tile shift and accepts the one
+// whose checksum matches.
+const (
+	markBlock = 8
+	markTile  = 16
+	markBits  = markTile * markTile
+	markStep  = 20.0 // quantizer step of the marked coefficients
+)
+
+// ForensicOwnerMax is the longest owner ID, in bytes, that fits the
+// invisible watermark.
+const ForensicOwnerMax = 24
+
+// ForensicMinQuality is the lowest quality the size optimizer picks for
+// outputs with an invisible watermark. The mark is lost somewhere below
+// quality 50, depending on the image; the rest is margin.
+const ForensicMinQuality = 60
+
+// markCoeffs are the (horizontal, vertical) frequencies carrying the bit.
+var markCoeffs = [][2]int{{1, 2}, {2, 1}}
+
+// markBasis holds the orthonormal 8-point DCT basis: markBasis[u][x].
+var markBasis = func() (b [markBlock][markBlock]float64) {
+	for u := range markBlock {
+		scale := math.Sqrt(2.0 / markBlock)
+		if u == 0 {
+			scale = math.Sqrt(1.0 / markBlock)
+		}
+		for x := range markBlock {
+			b[u][x] = scale * math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*markBlock))
+		}
+	}
+	return b
+}()
+
+// markWhitening is XORed over the payload so that long runs of equal bits,
+// like the zero padding of a short owner ID, don't mark whole rows of
+// blocks the same way.
+var markWhitening = sha256.Sum256([]byte("goimgtool invisible watermark"))
+
+// ErrNoForensicMark is returned by ExtractForensic when the image carries
+// no readable invisible watermark.
+var ErrNoForensicMark = errors.New("no invisible watermark found")
+
+// ForensicMark is the payload of the invisible watermark.
+type ForensicMark struct {
+	Owner   string
+	ImageID uint32
+}
+
+func (m ForensicMark) String() string {
+	return fmt.Sprintf("owner %q, image %s", m.Owner, formatImageID(m.ImageID))
+}
+
+func formatImageID(id uint32) string {
+	return fmt.Sprintf("%08x", id)
+}
+
+// forensicImageID identifies an output by its source's path relative to
+// the input folder, so reruns of the same folder mark files the same way.
+func forensicImageID(rel string) uint32 {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(rel)))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+func validateForensic(cfg *config.Config) error {
+	owner := cfg.ForensicOwner
+	if owner == "" {
+		return nil
+	}
+	if len(owner) > ForensicOwnerMax {
+		return fmt.Errorf("invisible watermark owner ID must be at most %d bytes", ForensicOwnerMax)
+	}
+	if strings.ContainsRune(owner, 0) {
+		return fmt.Errorf("invisible watermark owner ID must not contain NUL characters")
+	}
+	if cfg.MaxQuality > 0 && cfg.MaxQuality < ForensicMinQuality {
+		return fmt.Errorf("the invisible watermark needs a maximum quality of at least %d", ForensicMinQuality)
+	}
+	for _, r := range cfg.Renditions {
+		if r.MaxQuality > 0 && r.MaxQuality < ForensicMinQuality {
+			return fmt.Errorf("%s: the invisible watermark needs a maximum quality of at least %d", strings.TrimPrefix(r.Suffix(), "-"), ForensicMinQuality)
+		}
+	}
+	return nil
+}
+
+// bits encodes the payload with its CRC-32, whitened, one bit per entry.
+func (m ForensicMark) bits() [markBits]bool {
+	var payload [markBits / 8]byte
+	copy(payload[:ForensicOwnerMax], m.Owner)
+	binary.BigEndian.PutUint32(payload[ForensicOwnerMax:], m.ImageID)
+	binary.BigEndian.PutUint32(payload[ForensicOwnerMax+4:], crc32.ChecksumIEEE(payload[:ForensicOwnerMax+4]))
+	var bits [markBits]bool
+	for i := range bits {
+		bits[i] = (payload[i/8]^markWhitening[i/8])&(0x80>>(i%8)) != 0
+	}
+	return bits
+}
+
+// decodeMark is the inverse of bits. It reports false when the checksum
+// doesn't match.
+func decodeMark(bits *[markBits]bool) (ForensicMark, bool) {
+	var payload [markBits / 8]byte
+	for i, bit := range bits {
+		if bit {
+			payload[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	for i := range payload {
+		payload[i] ^= markWhitening[i]
+	}
+	sum := binary.BigEndian.Uint32(payload[ForensicOwnerMax+4:])
+	if crc32.ChecksumIEEE(payload[:ForensicOwnerMax+4]) != sum {
+		return ForensicMark{}, false
+	}
+	return ForensicMark{
+		Owner:   strings.TrimRight(string(payload[:ForensicOwnerMax]), "\x00"),
+		ImageID: binary.BigEndian.Uint32(payload[ForensicOwnerMax:]),
+	}, true
+}
+
+// embedForensic writes mark into img in place. The image needs at least one
+// whole tile of blocks.
+func embedForensic(img *image.NRGBA, mark ForensicMark) error {
+	bounds := img.Bounds()
+	cols, rows := bounds.Dx()/markBlock, bounds.Dy()/markBlock
+	if cols < markTile || rows < markTile {
+		return fmt.Errorf("image is too small for the invisible watermark, it needs at least %dx%d pixels", markTile*markBlock, markTile*markBlock)
+	}
+	bits := mark.bits()
+	var block, delta [markBlock][markBlock]float64
+	for by := range rows {
+		for bx := range cols {
+			x0, y0 := bounds.Min.X+bx*markBlock, bounds.Min.Y+by*markBlock
+			for y := range markBlock {
+				for x := range markBlock {
+					block[y][x] = luma(img.Pix[img.PixOffset(x0+x, y0+y):])
+					delta[y][x] = 0
+				}
+			}
+			bit := bits[(by%markTile)*markTile+bx%markTile]
+			for _, c := range markCoeffs {
+				coeff := dctCoeff(&block, c)
+				d := quantizeCoeff(coeff, bit) - coeff
+				for y := range markBlock {
+					for x := range markBlock {
+						delta[y][x] += d * markBasis[c[0]][x] * markBasis[c[1]][y]
+					}
+				}
+			}
+			for y := range markBlock {
+				for x := range markBlock {
+					pix := img.Pix[img.PixOffset(x0+x, y0+y):]
+					for ch := range 3 {
+						pix[ch] = uint8(min(max(math.Round(float64(pix[ch])+delta[y][x]), 0), 255))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ExtractForensic reads the invisible watermark from img, which may have
+// been re-encoded or cropped since it was marked, but not scaled.
+func ExtractForensic(img image.Image) (ForensicMark, error) {
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(bounds)
+	draw.Draw(nrgba, bounds, img, bounds.Min, draw.Src)
+	w, h := bounds.Dx(), bounds.Dy()
+	plane := make([]float64, w*h)
+	for y := range h {
+		for x := range w {
+			plane[y*w+x] = luma(nrgba.Pix[y*nrgba.Stride+x*4:])
+		}
+	}
+
+	var block [markBlock][markBlock]float64
+	var bits [markBits]bool
+	for oy := range markBlock {
+		for ox := range markBlock {
+			cols, rows := (w-ox)/markBlock, (h-oy)/markBlock
+			if cols < markTile || rows < markTile {
+				continue
+			}
+			// Soft decisions: +1 on the lattice of a zero bit, -1 on that
+			// of a one bit.
+			var tally [markBits]float64
+			for by := range rows {
+				for bx := range cols {
+					for y := range markBlock {
+						row := plane[(oy+by*markBlock+y)*w+ox+bx*markBlock:]
+						copy(block[y][:], row[:markBlock])
+					}
+					var soft float64
+					for _, c := range markCoeffs {
+						soft += math.Cos(2 * math.Pi * dctCoeff(&block, c) / markStep)
+					}
+					tally[(by%markTile)*markTile+bx%markTile] += soft
+				}
+			}
+			for sy := range markTile {
+				for sx := range markTile {
+					for i := range bits {
+						ty := (i/markTile - sy + markTile) % markTile
+						tx := (i%markTile - sx + markTile) % markTile
+						bits[i] = tally[ty*markTile+tx] < 0
+					}
+					if mark, ok := decodeMark(&bits); ok {
+						return mark, nil
+					}
+				}
+			}
+		}
+	}
+	return ForensicMark{}, ErrNoForensicMark
+}
+
+// luma is the Rec.601 luma of the RGB values at the start of pix, as JPEG
+// and WebP compute it.
+func luma(pix []uint8) float64 {
+	return 0.299*float64(pix[0]) + 0.587*float64(pix[1]) + 0.114*float64(pix[2])
+}
+
+// dctCoeff is the DCT coefficient of block at the frequencies c.
+func dctCoeff(block *[markBlock][markBlock]float64, c [2]int) float64 {
+	var sum float64
+	for y := range markBlock {
+		for x := range markBlock {
+			sum += block[y][x] * markBasis[c[0]][x] * markBasis[c[1]][y]
+		}
+	}
+	return sum
+}
+
+// quantizeCoeff moves coeff to the nearest point of the lattice for bit:
+// multiples of markStep for zero, shifted by half a step for one.
+func quantizeCoeff(coeff float64, bit bool) float64 {
+	var offset float64
+	if bit {
+		offset = markStep / 2
+	}
+	return math.Round((coeff-offset)/markStep)*markStep + offset
+}
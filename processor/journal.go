@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/del1x/GoIMGtool/config"
+)
+
+// JournalFile is the name of the job journal kept in the output folder
+// while a batch runs. It is removed when the batch completes, so its
+// presence marks a run that was interrupted.
+const JournalFile = ".goimgtool-journal.jsonl"
+
+// Journal is an unfinished run read back from its journal file.
+type Journal struct {
+	Input     string // absolute input folder
+	Format    string
+	Watermark string // absolute watermark path
+	Mode      string
+	Config    config.Config
+	Started   time.Time
+	Done      map[string]bool // slash-separated paths relative to Input
+	// RecipientsDir is set in the journal of one recipient's export: the
+	// folder holding every recipient's output, which the run never walks.
+	RecipientsDir string
+	// DoneRecipients holds, in the journal of a run over Config.Recipients,
+	// the IDs of the recipients whose export finished.
+	DoneRecipients map[string]bool
+}
+
+// journalHeader is the first line of the journal.
+type journalHeader struct {
+	Version   int           `json:"version"`
+	Input     string        `json:"input"`
+	Format    string        `json:"format"`
+	Watermark string        `json:"watermark"`
+	Mode      string        `json:"mode"`
+	Config    config.Config `json:"config"`
+	Started   time.Time     `json:"started"`
+
+	RecipientsDir string `json:"recipients_dir,omitempty"`
+}
+
+// journalRecord is written for every file that finishes and, in a run
+// over Config.Recipients, for every recipient whose export finishes.
+type journalRecord struct {
+	File      string `json:"file,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Recipient string `json:"recipient,omitempty"`
+}
+
+// journal appends records to the journal file. A nil journal discards them.
+type journal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func (p *ImageProcessor) journalHeader(r *run) journalHeader {
+	input, _ := filepath.Abs(r.imageDir)
+	watermark := p.WatermarkPath
+	if watermark != "" {
+		watermark, _ = filepath.Abs(watermark)
+	}
+	cfg := *p.Config
+	paths := []*string{&cfg.FontPath, &cfg.AdaptiveVariant, &cfg.PortraitWatermark, &cfg.LandscapeWatermark, &cfg.SquareWatermark}
+	cfg.Layers = slices.Clone(cfg.Layers)
+	for i := range cfg.Layers {
+		paths = append(paths, &cfg.Layers[i].Watermark, &cfg.Layers[i].FontPath)
+	}
+	for _, path := range paths {
+		if *path != "" {
+			*path, _ = filepath.Abs(*path)
+		}
+	}
+	return journalHeader{
+		Version:   1,
+		Input:     input,
+		Format:    r.format,
+		Watermark: watermark,
+		Mode:      p.WatermarkMode,
+		Config:    cfg,
+		Started:   r.started,
+
+		RecipientsDir: p.recipientsDir,
+	}
+}
+
+func createJournal(outputDir string, header journalHeader) (*journal, error) {
+	path := filepath.Join(outputDir, JournalFile)
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating journal: %v", err)
+	}
+	j := &journal{path: path, file: file}
+	if err := j.write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func appendJournal(outputDir string) (*journal, error) {
+	path := filepath.Join(outputDir, JournalFile)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %v", err)
+	}
+	return &journal{path: path, file: file}, nil
+}
+
+func (j *journal) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding journal record: %v", err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	// Sync so a power loss can't drop files that were reported as done.
+	return j.file.Sync()
+}
+
+func (j *journal) record(rel string, status FileStatus) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.write(journalRecord{File: filepath.ToSlash(rel), Status: status.String()}); err != nil {
+		fmt.Printf("Error recording %s in journal: %v\n", rel, err)
+	}
+}
+
+func (j *journal) recordRecipient(id string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.write(journalRecord{Recipient: id}); err != nil {
+		fmt.Printf("Error recording recipient %s in journal: %v\n", id, err)
+	}
+}
+
+func (j *journal) close() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.file.Close()
+}
+
+// finish closes and removes the journal once every file has been handled.
+func (j *journal) finish() {
+	if j == nil {
+		return
+	}
+	j.close()
+	if err := os.Remove(j.path); err != nil {
+		fmt.Printf("Error removing journal: %v\n", err)
+	}
+}
+
+// LoadJournal reads the journal of an interrupted run from outputDir.
+func LoadJournal(outputDir string) (*Journal, error) {
+	path := filepath.Join(outputDir, JournalFile)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no unfinished run in %s", outputDir)
+		}
+		return nil, fmt.Errorf("error opening journal: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("journal %s is empty", path)
+	}
+	var header journalHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("error parsing journal header: %v", err)
+	}
+	j := &Journal{
+		Input:     header.Input,
+		Format:    header.Format,
+		Watermark: header.Watermark,
+		Mode:      header.Mode,
+		Config:    header.Config,
+		Started:   header.Started,
+		Done:      make(map[string]bool),
+
+		RecipientsDir:  header.RecipientsDir,
+		DoneRecipients: make(map[string]bool),
+	}
+	for scanner.Scan() {
+		var rec journalRecord
+		// A torn last line from a crash is ignored; that file simply runs again.
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Recipient != "" {
+			j.DoneRecipients[rec.Recipient] = true
+			continue
+		}
+		switch rec.Status {
+		case StatusOK.String(), StatusSkipped.String():
+			j.Done[rec.File] = true
+		default:
+			delete(j.Done, rec.File)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading journal: %v", err)
+	}
+	return j, nil
+}
+
+// NewResumeProcessor builds a processor with the settings recorded in j.
+func NewResumeProcessor(j *Journal, fileHandler FileHandler) (*ImageProcessor, error) {
+	cfg := j.Config
+	var p *ImageProcessor
+	var err error
+	switch {
+	case cfg.Text != "":
+		p, err = NewTextProcessor(&cfg, fileHandler)
+	case j.Watermark != "":
+		p, err = NewImageProcessor(j.Watermark, &cfg, fileHandler)
+	default:
+		return nil, fmt.Errorf("journal does not record a watermark")
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.WatermarkMode = j.Mode
+	p.recipientsDir = j.RecipientsDir
+	return p, nil
+}
@@ -0,0 +1,34 @@
+package processor
+
+import "fmt"
+
+// loadLayers loads the watermark of every entry in Config.Layers. Each layer
+// is a processor of its own that shares the output settings of p but has
+// the watermark settings of the layer. Layers are not adapted to the image
+// and have no orientation variants.
+func (p *ImageProcessor) loadLayers() error {
+	p.layers = nil
+	for i, l := range p.Config.Layers {
+		cfg := *p.Config
+		cfg.WatermarkSettings = l.WatermarkSettings
+		cfg.Adaptive, cfg.AdaptiveVariant = false, ""
+		cfg.PortraitWatermark, cfg.LandscapeWatermark, cfg.SquareWatermark = "", "", ""
+		cfg.Layers = nil
+
+		var layer *ImageProcessor
+		var err error
+		if l.Text != "" {
+			layer, err = NewTextProcessor(&cfg, p.FileHandler)
+		} else {
+			layer, err = newImageProcessor(l.Watermark, &cfg, p.FileHandler)
+		}
+		if err != nil {
+			return fmt.Errorf("layer %d: %v", i+1, err)
+		}
+		if l.Mode != "" {
+			layer.WatermarkMode = l.Mode
+		}
+		p.layers = append(p.layers, layer)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ManifestFile is the name of the incremental-processing manifest kept in
+// the output folder.
+const ManifestFile = ".goimgtool-manifest.json"
+
+// manifestEntry remembers what a source file was last processed from and into.
+type manifestEntry struct {
+	Source   string   `json:"source"`   // SHA-256 of the source file
+	Settings string   `json:"settings"` // settings hash of the run that wrote it
+	Outputs  []string `json:"outputs"`  // relative to the output folder
+}
+
+type manifest struct {
+	mu      sync.Mutex
+	path    string
+	Version int                       `json:"version"`
+	Entries map[string]*manifestEntry `json:"entries"` // keyed by source path relative to the input folder
+}
+
+func loadManifest(outputDir string) (*manifest, error) {
+	m := &manifest{
+		path:    filepath.Join(outputDir, ManifestFile),
+		Version: 1,
+		Entries: make(map[string]*manifestEntry),
+	}
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %v", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %v", m.path, err)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]*manifestEntry)
+	}
+	return m, nil
+}
+
+func (m *manifest) lookup(rel string) (manifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.Entries[filepath.ToSlash(rel)]
+	if !ok {
+		return manifestEntry{}, false
+	}
+	return *e, true
+}
+
+func (m *manifest) record(rel string, entry *manifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[filepath.ToSlash(rel)] = entry
+}
+
+func (m *manifest) forget(rel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Entries, filepath.ToSlash(rel))
+}
+
+// save writes the manifest through a temporary file so a crash never
+// leaves it truncated.
+func (m *manifest) save() error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %v", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("error writing manifest: %v", err)
+	}
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashImage(img image.Image) string {
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok {
+		nrgba = image.NewNRGBA(img.Bounds())
+		draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%dx%d:", nrgba.Bounds().Dx(), nrgba.Bounds().Dy())
+	h.Write(nrgba.Pix)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// watermarkHash identifies the watermark source: the font or SVG file when
+// there is one, the decoded image otherwise.
+func (p *ImageProcessor) watermarkHash() string {
+	if p.sourceSum != "" {
+		return p.sourceSum
+	}
+	return hashImage(p.Watermark)
+}
+
+// settingsHash identifies everything that affects the bytes written for a
+// source file: the watermark, its mode and the output settings. Options that
+// only change how the batch runs are left out.
+func (p *ImageProcessor) settingsHash(format string) (string, error) {
+	cfg := *p.Config
+	cfg.Workers = 0
+	cfg.MemoryBudgetMB = 0
+	cfg.Recursive = false
+	cfg.MaxDepth = 0
+	cfg.SkipHidden = false
+	cfg.OutputDir = ""
+	cfg.Collision = ""
+	cfg.Incremental = false
+	cfg.PruneOrphans = false
+	var variant string
+	if p.variant != nil {
+		variant = p.variant.watermarkHash()
+	}
+	orientations := make(map[string]string)
+	for orientation, o := range p.orientations {
+		orientations[orientation] = o.watermarkHash()
+	}
+	var layers []string
+	for _, layer := range p.layers {
+		layers = append(layers, layer.watermarkHash())
+	}
+	data, err := json.Marshal(struct {
+		Config       any
+		Mode         string
+		Format       string
+		Watermark    string
+		Variant      string
+		Orientations map[string]string
+		Layers       []string
+	}{cfg, p.WatermarkMode, format, p.watermarkHash(), variant, orientations, layers})
+	if err != nil {
+		return "", fmt.Errorf("error hashing settings: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// upToDate reports whether rel was already processed from the same source
+// bytes with the same settings and all of its outputs still exist. The
+// current source hash is returned so it can be recorded after processing.
+func (p *ImageProcessor) upToDate(r *run, rel string) (bool, string) {
+	sourceHash, err := hashFile(filepath.Join(r.imageDir, rel))
+	if err != nil {
+		return false, ""
+	}
+	entry, ok := r.manifest.lookup(rel)
+	if !ok || entry.Source != sourceHash || entry.Settings != r.settings || len(entry.Outputs) == 0 {
+		return false, sourceHash
+	}
+	for _, out := range entry.Outputs {
+		if !p.FileHandler.Exists(filepath.Join(r.outputDir, filepath.FromSlash(out))) {
+			return false, sourceHash
+		}
+	}
+	// Keep the outputs reserved so the collision policy protects them.
+	r.mu.Lock()
+	for _, out := range entry.Outputs {
+		r.claimed[filepath.Join(r.outputDir, filepath.FromSlash(out))] = filepath.Join(r.imageDir, rel)
+	}
+	r.mu.Unlock()
+	return true, sourceHash
+}
+
+// pruneOrphans removes the outputs of manifest entries whose source file
+// no longer exists and returns the removed paths.
+func (p *ImageProcessor) pruneOrphans(r *run) []string {
+	r.manifest.mu.Lock()
+	var orphans []string
+	for rel := range r.manifest.Entries {
+		if !p.FileHandler.Exists(filepath.Join(r.imageDir, filepath.FromSlash(rel))) {
+			orphans = append(orphans, rel)
+		}
+	}
+	r.manifest.mu.Unlock()
+
+	var pruned []string
+	for _, rel := range orphans {
+		entry, _ := r.manifest.lookup(rel)
+		for _, out := range entry.Outputs {
+			path := filepath.Join(r.outputDir, filepath.FromSlash(out))
+			if err := p.FileHandler.Remove(path); err != nil {
+				fmt.Printf("Error pruning %s: %v\n", path, err)
+				continue
+			}
+			fmt.Printf("Pruned %s, its source %s is gone\n", path, rel)
+			pruned = append(pruned, path)
+		}
+		r.manifest.forget(rel)
+	}
+	return pruned
+}
@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultNameTemplate keeps the source file name and swaps the extension.
+const DefaultNameTemplate = "{name}.{ext}"
+
+var nameTokenPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+var nameTokens = map[string]bool{
+	"{name}":    true,
+	"{ext}":     true,
+	"{width}":   true,
+	"{height}":  true,
+	"{quality}": true,
+	"{index}":   true,
+	"{date}":    true,
+}
+
+// nameFields are the values substituted into a name template.
+type nameFields struct {
+	name    string // source file name without extension
+	ext     string // output format
+	width   int
+	height  int
+	quality int
+	index   int // 1-based position of the file in the batch
+	date    time.Time
+}
+
+// validateNameTemplate rejects unknown tokens and templates that would
+// write outside the output folder.
+func validateNameTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return fmt.Errorf("name template is empty")
+	}
+	if strings.ContainsAny(tmpl, `/\`) {
+		return fmt.Errorf("name template %q must not contain path separators", tmpl)
+	}
+	for _, token := range nameTokenPattern.FindAllString(tmpl, -1) {
+		if !nameTokens[token] {
+			return fmt.Errorf("unknown token %s in name template %q", token, tmpl)
+		}
+	}
+	return nil
+}
+
+func renderName(tmpl string, f nameFields) string {
+	return nameTokenPattern.ReplaceAllStringFunc(tmpl, func(token string) string {
+		switch token {
+		case "{name}":
+			return f.name
+		case "{ext}":
+			return f.ext
+		case "{width}":
+			return strconv.Itoa(f.width)
+		case "{height}":
+			return strconv.Itoa(f.height)
+		case "{quality}":
+			return strconv.Itoa(f.quality)
+		case "{index}":
+			return strconv.Itoa(f.index)
+		case "{date}":
+			return f.date.Format("2006-01-02")
+		default:
+			return token
+		}
+	})
+}
+
+// ResolveOutputDir returns outputDir, resolved against imageDir when it is
+// relative. An empty outputDir means DefaultOutputDir.
+func ResolveOutputDir(imageDir, outputDir string) string {
+	if outputDir == "" {
+		outputDir = DefaultOutputDir
+	}
+	if filepath.IsAbs(outputDir) {
+		return filepath.Clean(outputDir)
+	}
+	return filepath.Join(imageDir, outputDir)
+}
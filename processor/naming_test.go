@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenderName(t *testing.T) {
+	fields := nameFields{
+		name:    "photo",
+		ext:     "webp",
+		width:   1200,
+		height:  800,
+		quality: 82,
+		index:   7,
+		date:    time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{tmpl: DefaultNameTemplate, want: "photo.webp"},
+		{tmpl: "{name}_{width}x{height}_{quality}.{ext}", want: "photo_1200x800_82.webp"},
+		{tmpl: "{date}-{index}.{ext}", want: "2026-03-14-7.webp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			if err := validateNameTemplate(tt.tmpl); err != nil {
+				t.Fatalf("validateNameTemplate(%q) error = %v", tt.tmpl, err)
+			}
+			if got := renderName(tt.tmpl, fields); got != tt.want {
+				t.Errorf("renderName(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNameTemplateErrors(t *testing.T) {
+	for _, tmpl := range []string{"", "{name}.{format}", "../{name}.{ext}", "sub/{name}.{ext}"} {
+		if err := validateNameTemplate(tmpl); err == nil {
+			t.Errorf("validateNameTemplate(%q) expected error", tmpl)
+		}
+	}
+}
+
+func TestResolveOutputDir(t *testing.T) {
+	abs := filepath.Join(t.TempDir(), "out")
+	if got := ResolveOutputDir("photos", abs); got != abs {
+		t.Errorf("ResolveOutputDir(absolute) = %q, want %q", got, abs)
+	}
+	if got, want := ResolveOutputDir("photos", "done"), filepath.Join("photos", "done"); got != want {
+		t.Errorf("ResolveOutputDir(relative) = %q, want %q", got, want)
+	}
+	if got, want := ResolveOutputDir("photos", ""), filepath.Join("photos", DefaultOutputDir); got != want {
+		t.Errorf("ResolveOutputDir(empty) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+)
+
+// Image orientations for Config.PortraitWatermark and its siblings.
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+	OrientationSquare    = "square"
+)
+
+// squareTolerance is how far width and height may differ, relative to the
+// longer side, for an image to still count as square.
+const squareTolerance = 0.02
+
+func orientation(bounds image.Rectangle) string {
+	w, h := bounds.Dx(), bounds.Dy()
+	switch {
+	case float64(abs(w-h)) <= squareTolerance*float64(max(w, h)):
+		return OrientationSquare
+	case h > w:
+		return OrientationPortrait
+	default:
+		return OrientationLandscape
+	}
+}
+
+// loadOrientations loads the orientation-specific watermarks of the config.
+func (p *ImageProcessor) loadOrientations() error {
+	for orientation, path := range map[string]string{
+		OrientationPortrait:  p.Config.PortraitWatermark,
+		OrientationLandscape: p.Config.LandscapeWatermark,
+		OrientationSquare:    p.Config.SquareWatermark,
+	} {
+		if path == "" {
+			continue
+		}
+		o, err := newImageProcessor(path, p.Config, p.FileHandler)
+		if err != nil {
+			return fmt.Errorf("error loading %s watermark: %v", orientation, err)
+		}
+		if p.orientations == nil {
+			p.orientations = make(map[string]*ImageProcessor)
+		}
+		p.orientations[orientation] = o
+	}
+	return nil
+}
+
+// sourceFor returns the processor holding the watermark for an image with
+// the given bounds: the one for its orientation if set, p otherwise.
+func (p *ImageProcessor) sourceFor(bounds image.Rectangle) *ImageProcessor {
+	if o, ok := p.orientations[orientation(bounds)]; ok {
+		return o
+	}
+	return p
+}
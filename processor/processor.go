@@ -1,40 +1,95 @@
 package processor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
 	"github.com/del1x/GoIMGtool/config"
 	"github.com/del1x/GoIMGtool/fileio"
 	"github.com/disintegration/imaging"
+	"github.com/srwiley/oksvg"
+	"golang.org/x/image/font/opentype"
 )
 
-type ProgressCallback func(current, total int, img *canvas.Image, fileName string)
-
 type FileHandler interface {
 	LoadImage(path string) (image.Image, error)
-	SaveImage(img image.Image, path, format string, cfg *config.Config) error
+	SaveImage(img image.Image, path, format string, cfg *config.Config) (*fileio.SavedImage, error)
 	CreateDir(path string) error
 	ReadDir(path string) ([]os.DirEntry, error)
+	Rename(oldPath, newPath string) error
+	Remove(path string) error
+	Exists(path string) bool
 }
 
+// DefaultOutputDir is used when Config.OutputDir is empty.
+const DefaultOutputDir = "Images_watermarked"
+
 type ImageProcessor struct {
 	Watermark     image.Image
-	OutputDir     string
+	WatermarkPath string
+	Font          *opentype.Font // set for text watermarks instead of Watermark
 	Config        *config.Config
 	WatermarkMode string
 	FileHandler   FileHandler
+
+	sourceSum string // hash of the font or SVG file, for the manifest
+
+	svg   *oksvg.SvgIcon // vector source of Watermark, if it is an SVG
+	svgMu sync.Mutex
+
+	variant      *ImageProcessor            // Config.AdaptiveVariant, if set
+	orientations map[string]*ImageProcessor // orientation -> its own watermark
+	layers       []*ImageProcessor          // Config.Layers, in drawing order
+
+	recipientsDir string // parent of the recipient folders, never walked
 }
 
 func NewImageProcessor(watermarkPath string, cfg *config.Config, fileHandler FileHandler) (*ImageProcessor, error) {
+	p, err := newImageProcessor(watermarkPath, cfg, fileHandler)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.loadVariants(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// loadVariants loads the additional watermark files named in the config.
+func (p *ImageProcessor) loadVariants() error {
+	if err := p.loadAdaptiveVariant(); err != nil {
+		return err
+	}
+	if err := p.loadOrientations(); err != nil {
+		return err
+	}
+	return p.loadLayers()
+}
+
+func newImageProcessor(watermarkPath string, cfg *config.Config, fileHandler FileHandler) (*ImageProcessor, error) {
+	if isSVG(watermarkPath) {
+		p := &ImageProcessor{
+			WatermarkPath: watermarkPath,
+			Config:        cfg,
+			WatermarkMode: "crop",
+			FileHandler:   fileHandler,
+		}
+		if err := p.loadSVG(watermarkPath); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
 	watermark, err := fileHandler.LoadImage(watermarkPath)
 	if err != nil {
 		return nil, fmt.Errorf("error loading watermark: %v", err)
@@ -44,130 +99,584 @@ func NewImageProcessor(watermarkPath string, cfg *config.Config, fileHandler Fil
 	}
 	return &ImageProcessor{
 		Watermark:     watermark,
-		OutputDir:     "Images_watermarked",
+		WatermarkPath: watermarkPath,
 		Config:        cfg,
 		WatermarkMode: "crop",
 		FileHandler:   fileHandler,
 	}, nil
 }
 
-func (p *ImageProcessor) ProcessFolder(imageDir, outputFormat string, progress ProgressCallback) error {
+// run holds the settings shared by every file of one ProcessFolderContext call.
+type run struct {
+	imageDir  string
+	outputDir string
+	format    string
+	started   time.Time
+
+	mu      sync.Mutex
+	claimed map[string]string // output path -> input path that claimed it
+
+	manifest *manifest // nil unless Config.Incremental is set
+	settings string    // settingsHash of the run
+	journal  *journal
+}
+
+// OutputDirFor returns the folder that processing imageDir writes to.
+func (p *ImageProcessor) OutputDirFor(imageDir string) string {
+	return ResolveOutputDir(imageDir, p.Config.OutputDir)
+}
+
+func (p *ImageProcessor) ProcessFolder(imageDir, outputFormat string, progress ProgressCallback) (*BatchResult, error) {
+	return p.ProcessFolderContext(context.Background(), imageDir, outputFormat, progress)
+}
+
+// ProcessFolderContext is like ProcessFolder but stops dispatching new files
+// once ctx is done. Files already being processed are abandoned between
+// stages; an encode that has started runs to completion. Files that never
+// ran are reported as skipped. The returned error is only set when the run
+// could not start or was cancelled; per-file failures are in the result.
+// With Config.Recipients set, the folder is processed once per recipient.
+func (p *ImageProcessor) ProcessFolderContext(ctx context.Context, imageDir, outputFormat string, progress ProgressCallback) (*BatchResult, error) {
+	if len(p.Config.Recipients) > 0 {
+		return p.processRecipients(ctx, imageDir, outputFormat, nil, progress)
+	}
+	return p.processFolder(ctx, imageDir, outputFormat, nil, progress)
+}
+
+// Resume continues the unfinished run recorded in j, processing only the
+// files that did not complete. Use NewResumeProcessor to get a processor
+// with the settings of the original run. A run over Config.Recipients
+// continues with the recipients whose export did not finish.
+func (p *ImageProcessor) Resume(ctx context.Context, j *Journal, progress ProgressCallback) (*BatchResult, error) {
+	if len(p.Config.Recipients) > 0 {
+		return p.processRecipients(ctx, j.Input, j.Format, j, progress)
+	}
+	return p.processFolder(ctx, j.Input, j.Format, j, progress)
+}
+
+// job is a file to process and its 1-based position in the full batch.
+type job struct {
+	index int
+	rel   string
+}
+
+func (p *ImageProcessor) processFolder(ctx context.Context, imageDir, outputFormat string, resume *Journal, progress ProgressCallback) (*BatchResult, error) {
 	startTime := time.Now()
-	files, err := p.FileHandler.ReadDir(imageDir)
+	r := &run{
+		imageDir:  imageDir,
+		outputDir: p.OutputDirFor(imageDir),
+		format:    outputFormat,
+		started:   startTime,
+		claimed:   make(map[string]string),
+	}
+	if resume != nil {
+		r.started = resume.Started
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	files, skipped, err := p.collectImages(r)
 	if err != nil {
-		return fmt.Errorf("error reading directory: %v", err)
+		return nil, err
+	}
+	var jobs []job
+	for i, rel := range files {
+		if resume != nil && resume.Done[filepath.ToSlash(rel)] {
+			skipped = append(skipped, FileResult{InputPath: filepath.Join(imageDir, rel), Status: StatusSkipped, Reason: "completed before resume"})
+			continue
+		}
+		jobs = append(jobs, job{index: i + 1, rel: rel})
 	}
-	total := len(files)
+	total := len(jobs)
 
-	if err := p.setupOutputDir(); err != nil {
-		return err
+	if err := p.FileHandler.CreateDir(r.outputDir); err != nil {
+		return nil, err
+	}
+	if resume != nil {
+		r.journal, err = appendJournal(r.outputDir)
+	} else {
+		r.journal, err = createJournal(r.outputDir, p.journalHeader(r))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.Config.Incremental {
+		if r.manifest, err = loadManifest(r.outputDir); err != nil {
+			return nil, err
+		}
+		if r.settings, err = p.settingsHash(outputFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := make([]string, len(jobs))
+	for i, j := range jobs {
+		pending[i] = j.rel
 	}
+	workers := p.workerCount(imageDir, pending)
+	fmt.Printf("Processing %d files with %d workers\n", total, workers)
 
-	const maxGoroutines = 4
 	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, maxGoroutines)
+	semaphore := make(chan struct{}, workers)
+	var mu sync.Mutex
 	current := 0
+	results := make([]FileResult, total)
+	report := func(ev Event) {
+		if progress != nil {
+			progress(ev)
+		}
+	}
+
+	for i, j := range jobs {
+		results[i] = FileResult{
+			InputPath: filepath.Join(imageDir, j.rel),
+			Status:    StatusSkipped,
+			Reason:    "cancelled",
+		}
+	}
 
-	for _, file := range files {
+dispatch:
+	for i, j := range jobs {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case semaphore <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			<-semaphore
+			break
+		}
 		wg.Add(1)
-		semaphore <- struct{}{}
 
-		go func(f os.DirEntry) {
+		go func(i int, j job) {
+			rel := j.rel
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			outputPath, err := p.processFile(imageDir, f, outputFormat)
-			if err != nil {
-				fmt.Printf("Error processing file %s: %v\n", f.Name(), err)
-				return
+			mu.Lock()
+			report(Event{Type: FileStarted, Current: current, Total: total, FileName: rel})
+			mu.Unlock()
+
+			fileStart := time.Now()
+			res := &results[i]
+			err := p.processFile(ctx, r, j.index, rel, res)
+			res.Duration = time.Since(fileStart)
+			var skip *skipError
+			switch {
+			case err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()):
+				fmt.Printf("Cancelled processing of %s\n", rel)
+			case errors.As(err, &skip):
+				fmt.Printf("Skipping file %s: %v\n", rel, skip)
+				res.Status, res.Reason = StatusSkipped, skip.reason
+			case err != nil:
+				fmt.Printf("Error processing file %s: %v\n", rel, err)
+				res.Status, res.Reason, res.Err = StatusFailed, "", err
+				if r.manifest != nil {
+					r.manifest.forget(rel)
+				}
+			default:
+				res.Status, res.Reason = StatusOK, ""
 			}
+
+			if res.Status != StatusSkipped || skip != nil {
+				r.journal.record(rel, res.Status)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
 			current++
-			if progress != nil {
-				fyne.Do(func() {
-					canvasImg := p.displayImageInUI(outputPath)
-					progress(current, total, canvasImg, f.Name())
-				})
+			ev := Event{
+				Type:       FileFinished,
+				Current:    current,
+				Total:      total,
+				FileName:   rel,
+				OutputPath: res.OutputPath,
+				Width:      res.Width,
+				Height:     res.Height,
+				Quality:    res.Quality,
+				SizeBytes:  res.Size,
 			}
-		}(file)
+			switch {
+			case res.Status == StatusSkipped:
+				// Deliberately skipped, or abandoned because the run was
+				// cancelled.
+				ev.Type = FileSkipped
+			case err != nil:
+				ev.Type = FileFailed
+				ev.Err = err
+			}
+			report(ev)
+		}(i, j)
 	}
 
 	wg.Wait()
-	if progress != nil {
-		fyne.Do(func() {
-			progress(total, total, nil, "")
-		})
-	}
 
-	elapsed := time.Since(startTime)
-	fmt.Printf("Processing completed in %v\n", elapsed)
-	return nil
+	batch := &BatchResult{
+		Files:   append(skipped, results...),
+		Elapsed: time.Since(startTime),
+	}
+	sort.SliceStable(batch.Files, func(a, b int) bool {
+		return batch.Files[a].InputPath < batch.Files[b].InputPath
+	})
+	if r.manifest != nil {
+		if p.Config.PruneOrphans && ctx.Err() == nil {
+			batch.Pruned = p.pruneOrphans(r)
+		}
+		if err := r.manifest.save(); err != nil {
+			fmt.Printf("Error saving manifest: %v\n", err)
+		}
+	}
+	if ctx.Err() == nil {
+		r.journal.finish()
+	} else {
+		r.journal.close()
+	}
+	if err := ctx.Err(); err != nil {
+		batch.Cancelled = true
+		fmt.Printf("Processing cancelled: %v\n", batch)
+		return batch, fmt.Errorf("processing cancelled after %d of %d files: %w", batch.Count(StatusOK), total, err)
+	}
+	fmt.Printf("Processing completed: %v\n", batch)
+	return batch, nil
 }
 
-func (p *ImageProcessor) processFile(imageDir string, file os.DirEntry, outputFormat string) (string, error) {
-	if p.isWatermarkFile(file, imageDir) {
-		fmt.Println("Skipping watermark.png")
-		return "", nil
+// processFile runs one file through the pipeline and fills res with the
+// output details. A *skipError means the file was deliberately not written.
+func (p *ImageProcessor) processFile(ctx context.Context, r *run, index int, rel string, res *FileResult) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	if !p.isSupportedExtension(file) {
-		fmt.Printf("Skipping file %s: unsupported extension %s\n", file.Name(), filepath.Ext(file.Name()))
-		return "", nil
+	inputPath := filepath.Join(r.imageDir, rel)
+	var sourceHash string
+	if r.manifest != nil {
+		var fresh bool
+		if fresh, sourceHash = p.upToDate(r, rel); fresh {
+			return &skipError{reason: "unchanged since last run"}
+		}
 	}
-
-	img, err := p.FileHandler.LoadImage(filepath.Join(imageDir, file.Name()))
+	img, err := p.FileHandler.LoadImage(inputPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to load image %s: %v", file.Name(), err)
+		return &FileError{Op: "load", Path: inputPath, Err: err}
 	}
 	if img == nil {
-		return "", fmt.Errorf("loaded image for %s is nil", file.Name())
+		return &FileError{Op: "load", Path: inputPath, Err: errors.New("decoded image is nil")}
 	}
 	img, err = p.resizeImage(img)
 	if err != nil {
-		return "", err
+		return &FileError{Op: "resize", Path: inputPath, Err: err}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	result, err := p.applyWatermark(img)
+	result, anchors, err := p.applyWatermark(img)
 	if err != nil {
-		return "", err
+		return &FileError{Op: "watermark", Path: inputPath, Err: err}
 	}
-	outputPath := filepath.Join(p.OutputDir, strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))+"."+outputFormat)
-	if err := p.FileHandler.SaveImage(result, outputPath, outputFormat, p.Config); err != nil {
-		return "", err
+	res.Anchors = anchors
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	outputDir := filepath.Join(r.outputDir, filepath.Dir(rel))
+	if err := p.FileHandler.CreateDir(outputDir); err != nil {
+		return &FileError{Op: "save", Path: outputDir, Err: err}
+	}
+
+	// A target size missed by one rendition doesn't stop the others; the
+	// first such error is returned once all are written.
+	var outputs []string
+	var sizeErr error
+	for _, rn := range p.renditions(result) {
+		if p.Config.ForensicOwner != "" {
+			// Embedded per rendition, since scaling would destroy the mark.
+			mark := ForensicMark{Owner: p.Config.ForensicOwner, ImageID: forensicImageID(rel)}
+			if err := embedForensic(rn.img, mark); err != nil {
+				return &FileError{Op: "watermark", Path: inputPath, Err: err}
+			}
+			res.ImageID = formatImageID(mark.ImageID)
+		}
+		out, err := p.saveOutput(r, index, rel, outputDir, rn, res)
+		if out == nil {
+			return err
+		}
+		outputs = append(outputs, out.Path)
+		if len(p.Config.Renditions) > 0 {
+			res.Renditions = append(res.Renditions, *out)
+		}
+		if len(outputs) == 1 {
+			res.OutputPath = out.Path
+			res.Width, res.Height = out.Width, out.Height
+			res.Quality = out.Quality
+			res.Size = out.Size
+		}
+		if err != nil && sizeErr == nil {
+			sizeErr = err
+		}
+	}
+	if sizeErr != nil {
+		return sizeErr
+	}
+	if r.manifest != nil && sourceHash != "" {
+		entry := &manifestEntry{Source: sourceHash, Settings: r.settings}
+		for _, path := range outputs {
+			out, _ := filepath.Rel(r.outputDir, path)
+			entry.Outputs = append(entry.Outputs, filepath.ToSlash(out))
+		}
+		r.manifest.record(rel, entry)
+	}
+	return nil
+}
+
+// saveOutput encodes one rendition of a file under its final name. The
+// returned output is nil when nothing was written; a non-nil output with an
+// error means the file was written but missed its target size.
+func (p *ImageProcessor) saveOutput(r *run, index int, rel, outputDir string, rn rendition, res *FileResult) (*Output, error) {
+	inputPath := filepath.Join(r.imageDir, rel)
+	fields := nameFields{
+		name:   strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel)),
+		ext:    r.format,
+		width:  rn.img.Bounds().Dx(),
+		height: rn.img.Bounds().Dy(),
+		index:  index,
+		date:   r.started,
+	}
+	tmpl := p.nameTemplate()
+	name := func() string {
+		name := renderName(tmpl, fields)
+		ext := filepath.Ext(name)
+		return filepath.Join(outputDir, strings.TrimSuffix(name, ext)+rn.suffix+ext)
+	}
+
+	// Unless the name depends on the encoded quality, settle collisions
+	// before spending time on the encode.
+	var outputPath string
+	var err error
+	if !strings.Contains(tmpl, "{quality}") {
+		outputPath, err = p.claimOutput(r, name(), inputPath, res)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Encode under a temporary name first: the final name may depend on the
+	// quality the optimizer picks, and a crash never leaves a partial file
+	// under the final name.
+	tempPath := filepath.Join(outputDir, fmt.Sprintf(".goimgtool-%d%s.%s", index, rn.suffix, r.format))
+	saved, saveErr := p.FileHandler.SaveImage(rn.img, tempPath, r.format, rn.cfg)
+	if saved == nil {
+		// The encoder may have left a partial file behind.
+		p.FileHandler.Remove(tempPath)
+		r.releaseOutput(outputPath, inputPath)
+		return nil, &FileError{Op: "save", Path: tempPath, Err: saveErr}
+	}
+
+	if outputPath == "" {
+		fields.quality = saved.Quality
+		outputPath, err = p.claimOutput(r, name(), inputPath, res)
+		if err != nil {
+			p.FileHandler.Remove(saved.Path)
+			return nil, err
+		}
+	}
+	if err := p.FileHandler.Rename(saved.Path, outputPath); err != nil {
+		p.FileHandler.Remove(saved.Path)
+		r.releaseOutput(outputPath, inputPath)
+		return nil, &FileError{Op: "save", Path: outputPath, Err: err}
 	}
 	fmt.Printf("Image saved to %s\n", outputPath)
-	return outputPath, nil
+	out := &Output{Path: outputPath, Width: saved.Width, Height: saved.Height, Quality: saved.Quality, Size: saved.Size}
+	if saveErr != nil {
+		return out, &FileError{Op: "save", Path: outputPath, Err: saveErr}
+	}
+	return out, nil
+}
+
+// validate checks the settings of a run before any file is touched.
+func (p *ImageProcessor) validate() error {
+	if err := validateNameTemplate(p.nameTemplate()); err != nil {
+		return err
+	}
+	if p.Config.Opacity < 0 || p.Config.Opacity > 100 {
+		return fmt.Errorf("watermark opacity must be between 0 and 100 percent")
+	}
+	if err := validateBlendMode(p.Config.BlendMode); err != nil {
+		return err
+	}
+	if p.Font != nil {
+		if err := validateText(p.Config, p.WatermarkMode); err != nil {
+			return err
+		}
+	}
+	switch p.WatermarkMode {
+	case "crop", "resize":
+	case "anchor":
+		if err := validateAnchor(p.Config); err != nil {
+			return err
+		}
+	case "tile":
+		if err := validateTile(p.Config); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown watermark mode %q", p.WatermarkMode)
+	}
+	if err := validateResize(p.Config); err != nil {
+		return err
+	}
+	if err := config.ValidateRenditions(p.Config.Renditions); err != nil {
+		return err
+	}
+	if err := validateForensic(p.Config); err != nil {
+		return err
+	}
+	for i, layer := range p.layers {
+		if err := layer.validate(); err != nil {
+			return fmt.Errorf("layer %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateResize(cfg *config.Config) error {
+	switch cfg.ResizeMode {
+	case "", config.ResizeFit:
+		return nil
+	case config.ResizeFill, config.ResizePad:
+		if cfg.ResizeMode == config.ResizeFill && !slices.Contains(config.CropAnchors, cfg.CropAnchor) {
+			return fmt.Errorf("unknown crop anchor %q", cfg.CropAnchor)
+		}
+		if cfg.ResizeMode == config.ResizePad && cfg.PadBackground != config.PadBlur {
+			if _, err := config.ParseColor(cfg.PadBackground); err != nil {
+				return fmt.Errorf("pad background: %v", err)
+			}
+		}
+		if cfg.MaxWidth < 1 || cfg.MaxHeight < 1 {
+			return fmt.Errorf("the %s resize mode needs a width and a height", cfg.ResizeMode)
+		}
+		// Renditions are scaled down from the exact canvas, so none can be
+		// larger than it.
+		for _, r := range cfg.Renditions {
+			if r.Width > cfg.MaxWidth || r.Height > cfg.MaxHeight {
+				return fmt.Errorf("rendition %s is larger than the %dx%d canvas of the %s resize mode",
+					strings.TrimPrefix(r.Suffix(), "-"), cfg.MaxWidth, cfg.MaxHeight, cfg.ResizeMode)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown resize mode %q", cfg.ResizeMode)
+	}
+}
+
+func (p *ImageProcessor) nameTemplate() string {
+	if p.Config.NameTemplate == "" {
+		return DefaultNameTemplate
+	}
+	return p.Config.NameTemplate
 }
 
+// resizeImage scales img to the output size before it is watermarked. With
+// renditions in the fit mode, that is the size of the largest rendition.
 func (p *ImageProcessor) resizeImage(img image.Image) (image.Image, error) {
+	cfg := p.Config
+	if len(cfg.Renditions) > 0 && (cfg.ResizeMode == "" || cfg.ResizeMode == config.ResizeFit) {
+		c := *cfg
+		c.MaxWidth, c.MaxHeight = p.renditionBounds()
+		cfg = &c
+	}
 	width := img.Bounds().Dx()
 	height := img.Bounds().Dy()
-	if width > p.Config.MaxWidth || height > p.Config.MaxHeight {
-		img = fileio.HandleImageResize(img, p.Config)
+	exact := cfg.ResizeMode == config.ResizeFill || cfg.ResizeMode == config.ResizePad
+	if width > cfg.MaxWidth || height > cfg.MaxHeight ||
+		exact && (width != cfg.MaxWidth || height != cfg.MaxHeight) {
+		img = fileio.HandleImageResize(img, cfg)
 		fmt.Printf("Resized image to %dx%d\n", img.Bounds().Dx(), img.Bounds().Dy())
 	}
 	return img, nil
 }
 
-func (p *ImageProcessor) applyWatermark(img image.Image) (image.Image, error) {
-	watermark := p.prepareWatermark(img)
+// applyWatermark draws the main watermark and then every layer onto a copy
+// of img. It also returns the anchors used by the watermarks drawn in the
+// anchor mode, in drawing order.
+func (p *ImageProcessor) applyWatermark(img image.Image) (*image.NRGBA, []string, error) {
 	bounds := img.Bounds()
-	transparentWatermark := image.NewNRGBA(bounds)
-	draw.Draw(transparentWatermark, bounds, watermark, image.Point{0, 0}, draw.Src)
-
 	result := image.NewNRGBA(bounds)
-	draw.Draw(result, bounds, img, image.Point{0, 0}, draw.Src)
-	draw.Draw(result, bounds, transparentWatermark, image.Point{0, 0}, draw.Over)
-	return result, nil
+	draw.Draw(result, bounds, img, bounds.Min, draw.Src)
+	var anchors []string
+	anchor, err := p.drawWatermark(result, img)
+	if err != nil {
+		return nil, nil, err
+	}
+	if anchor != "" {
+		anchors = append(anchors, anchor)
+	}
+	for i, layer := range p.layers {
+		anchor, err := layer.drawWatermark(result, result)
+		if err != nil {
+			return nil, nil, fmt.Errorf("layer %d: %v", i+1, err)
+		}
+		if anchor != "" {
+			anchors = append(anchors, anchor)
+		}
+	}
+	return result, anchors, nil
+}
+
+// placement is a watermark prepared for one image.
+type placement struct {
+	watermark image.Image
+	at        image.Rectangle // where watermark goes in the image
+	anchor    string          // the anchor used, in the anchor mode
+}
+
+// drawWatermark composites the watermark of p onto dst. img is what the
+// watermark is placed and adapted for; it has the same bounds as dst. It
+// returns the anchor used in the anchor mode.
+func (p *ImageProcessor) drawWatermark(dst *image.NRGBA, img image.Image) (string, error) {
+	pl, err := p.sourceFor(img.Bounds()).placeWatermark(img, p.WatermarkMode)
+	if err != nil {
+		return "", err
+	}
+	if p.Config.Adaptive {
+		if pl, err = p.adaptWatermark(img, pl); err != nil {
+			return "", err
+		}
+	}
+	if p.Config.BlendMode == "" || p.Config.BlendMode == config.BlendNormal {
+		draw.DrawMask(dst, pl.at, pl.watermark, pl.watermark.Bounds().Min, p.opacityMask(), image.Point{}, draw.Over)
+	} else {
+		blend(dst, pl.at, pl.watermark, p.Config.BlendMode, float64(p.Config.Opacity)/100)
+	}
+	return pl.anchor, nil
 }
 
-func (p *ImageProcessor) setupOutputDir() error {
-	return p.FileHandler.CreateDir(p.OutputDir)
+// placeWatermark prepares the watermark for img in the given mode.
+func (p *ImageProcessor) placeWatermark(img image.Image, mode string) (placement, error) {
+	bounds := img.Bounds()
+	switch mode {
+	case "anchor":
+		return p.anchorWatermark(img)
+	case "tile":
+		watermark, err := p.tileWatermark(bounds)
+		return placement{watermark: watermark, at: bounds}, err
+	default:
+		return placement{watermark: p.prepareWatermark(img), at: bounds}, nil
+	}
 }
 
-func (p *ImageProcessor) isWatermarkFile(file os.DirEntry, imageDir string) bool {
-	return file.Name() == filepath.Base(filepath.Join(imageDir, "watermark.png"))
+// opacityMask scales the watermark alpha by Config.Opacity. A nil mask
+// leaves it as it is.
+func (p *ImageProcessor) opacityMask() image.Image {
+	if p.Config.Opacity >= 100 {
+		return nil
+	}
+	return image.NewUniform(color.Alpha{A: uint8(p.Config.Opacity * 255 / 100)})
+}
+
+func (p *ImageProcessor) isWatermarkFile(name string) bool {
+	return name == "watermark.png"
 }
 
-func (p *ImageProcessor) isSupportedExtension(file os.DirEntry) bool {
-	ext := strings.ToLower(filepath.Ext(file.Name()))
+func (p *ImageProcessor) isSupportedExtension(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
 	return ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".webp"
 }
 
@@ -176,11 +685,11 @@ func (p *ImageProcessor) prepareWatermark(img image.Image) image.Image {
 	imgBounds := img.Bounds()
 
 	if p.WatermarkMode == "resize" {
-		return imaging.Resize(p.Watermark, imgBounds.Dx(), imgBounds.Dy(), imaging.Lanczos)
+		return p.resizedWatermark(imgBounds.Dx(), imgBounds.Dy())
 	}
 
 	if wmBounds.Dx() <= imgBounds.Dx() && wmBounds.Dy() <= imgBounds.Dy() {
-		return imaging.Resize(p.Watermark, imgBounds.Dx(), imgBounds.Dy(), imaging.Lanczos)
+		return p.resizedWatermark(imgBounds.Dx(), imgBounds.Dy())
 	}
 
 	cropX := (wmBounds.Dx() - imgBounds.Dx()) / 2
@@ -192,24 +701,3 @@ func (p *ImageProcessor) prepareWatermark(img image.Image) image.Image {
 
 	return croppedWatermark
 }
-
-func (p *ImageProcessor) displayImageInUI(path string) *canvas.Image {
-	if path == "" {
-		return nil
-	}
-	file, err := os.Open(path)
-	if err != nil {
-		fmt.Printf("Error opening file: %v\n", err)
-		return nil
-	}
-	defer file.Close()
-	img, _, err := image.Decode(file)
-	if err != nil {
-		fmt.Printf("Error decoding image for UI: %v", err)
-		return nil
-	}
-	canvasImg := canvas.NewImageFromImage(img)
-	canvasImg.FillMode = canvas.ImageFillContain
-	canvasImg.SetMinSize(fyne.NewSize(200, 200))
-	return canvasImg
-}
@@ -0,0 +1,1068 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/del1x/GoIMGtool/config"
+	"github.com/del1x/GoIMGtool/fileio"
+)
+
+// fakeHandler serves solid images for every path and writes empty files
+// instead of encoding them, recording the final output paths.
+type fakeHandler struct {
+	mu          sync.Mutex
+	saved       []string
+	configs     []config.Config // settings of every SaveImage call, in order
+	failOn      string
+	failRenames int // renames to fail before the rest succeed
+	imgSize     image.Point
+}
+
+func (h *fakeHandler) LoadImage(path string) (image.Image, error) {
+	if filepath.Base(path) == h.failOn {
+		return nil, errors.New("corrupt file")
+	}
+	size := h.imgSize
+	if size == (image.Point{}) {
+		size = image.Pt(64, 48)
+	}
+	img := image.NewNRGBA(image.Rectangle{Max: size})
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	return img, nil
+}
+
+func (h *fakeHandler) SaveImage(img image.Image, path, format string, cfg *config.Config) (*fileio.SavedImage, error) {
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	h.configs = append(h.configs, *cfg)
+	h.mu.Unlock()
+	return &fileio.SavedImage{Path: path, Width: img.Bounds().Dx(), Height: img.Bounds().Dy(), Quality: 80, Size: 2048}, nil
+}
+
+func (h *fakeHandler) Rename(oldPath, newPath string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failRenames > 0 {
+		h.failRenames--
+		return errors.New("permission denied")
+	}
+	h.saved = append(h.saved, newPath)
+	return os.Rename(oldPath, newPath)
+}
+
+func (h *fakeHandler) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (h *fakeHandler) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (h *fakeHandler) ImageSize(path string) (int, int, error) {
+	return h.imgSize.X, h.imgSize.Y, nil
+}
+
+func (h *fakeHandler) CreateDir(path string) error {
+	return os.MkdirAll(path, 0755)
+}
+
+func (h *fakeHandler) ReadDir(path string) ([]os.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
+func newTestProcessor(t *testing.T, handler *fakeHandler, names ...string) (*ImageProcessor, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wm := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for i := range wm.Pix {
+		wm.Pix[i] = 0x80
+	}
+	cfg := config.JpgConfig().WithOutput(filepath.Join(t.TempDir(), "out"), "{name}.{ext}")
+	return &ImageProcessor{
+		Watermark:     wm,
+		Config:        cfg,
+		WatermarkMode: "crop",
+		FileHandler:   handler,
+	}, dir
+}
+
+func TestProcessFolderEvents(t *testing.T) {
+	handler := &fakeHandler{failOn: "b.jpg"}
+	p, dir := newTestProcessor(t, handler, "a.jpg", "b.jpg", "c.png", "notes.txt")
+
+	var events []Event
+	result, err := p.ProcessFolder(dir, "webp", func(ev Event) {
+		events = append(events, ev)
+	})
+	if err != nil {
+		t.Fatalf("ProcessFolder() error = %v", err)
+	}
+	if result.Count(StatusOK) != 2 || result.Count(StatusSkipped) != 1 || result.Count(StatusFailed) != 1 {
+		t.Errorf("ProcessFolder() result = %v, want 2 ok, 1 skipped, 1 failed", result)
+	}
+	for _, f := range result.Failed() {
+		var fileErr *FileError
+		if !errors.As(f.Err, &fileErr) || fileErr.Op != "load" {
+			t.Errorf("failed file %s error = %v, want load FileError", f.InputPath, f.Err)
+		}
+	}
+
+	counts := map[EventType]int{}
+	for _, ev := range events {
+		counts[ev.Type]++
+		if ev.Total != 3 {
+			t.Errorf("event %v total = %d, want 3", ev.Type, ev.Total)
+		}
+		switch ev.Type {
+		case FileFinished:
+			if ev.OutputPath == "" || ev.Width != 64 || ev.Height != 48 || ev.Quality != 80 || ev.SizeBytes != 2048 {
+				t.Errorf("finished event missing output details: %+v", ev)
+			}
+		case FileFailed:
+			if ev.FileName != "b.jpg" || ev.Err == nil {
+				t.Errorf("unexpected failed event: %+v", ev)
+			}
+		}
+	}
+	if counts[FileStarted] != 3 || counts[FileFinished] != 2 || counts[FileFailed] != 1 {
+		t.Errorf("event counts = %v, want 3 started, 2 finished, 1 failed", counts)
+	}
+}
+
+func TestProcessFolderContextCancelled(t *testing.T) {
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler, "a.jpg", "b.jpg")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := p.ProcessFolderContext(ctx, dir, "jpg", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ProcessFolderContext() error = %v, want context.Canceled", err)
+	}
+	if !result.Cancelled || result.Count(StatusSkipped) != 2 {
+		t.Errorf("ProcessFolderContext() result = %+v, want 2 skipped and cancelled", result)
+	}
+	if len(handler.saved) != 0 {
+		t.Errorf("saved %v after cancellation, want nothing", handler.saved)
+	}
+}
+
+func TestWorkerCountMemoryBudget(t *testing.T) {
+	handler := &fakeHandler{imgSize: image.Pt(4000, 3000)}
+	p, dir := newTestProcessor(t, handler)
+	p.Config.WithMaxSize(1000, 1000).WithWorkers(8)
+	names := []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg", "f.jpg", "g.jpg", "h.jpg", "i.jpg"}
+
+	if got := p.workerCount(dir, names); got != 8 {
+		t.Errorf("workerCount() without budget = %d, want 8", got)
+	}
+
+	// (12M source + 4 * 1M output pixels) * 4 bytes is about 61 MB per file.
+	p.Config.WithMemoryBudget(200)
+	if got := p.workerCount(dir, names); got != 3 {
+		t.Errorf("workerCount() with 200 MB budget = %d, want 3", got)
+	}
+	p.Config.WithMemoryBudget(10)
+	if got := p.workerCount(dir, names); got != 1 {
+		t.Errorf("workerCount() with 10 MB budget = %d, want 1", got)
+	}
+}
+
+func TestProcessFolderRecursive(t *testing.T) {
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler,
+		"a.jpg", "shoes/men/b.jpg", "shoes/men/deep/c.jpg", ".cache/d.jpg")
+	p.Config.WithRecursive(2)
+
+	result, err := p.ProcessFolder(dir, "jpg", nil)
+	if err != nil {
+		t.Fatalf("ProcessFolder() error = %v", err)
+	}
+	if result.Count(StatusOK) != 2 {
+		t.Fatalf("ProcessFolder() result = %v, want 2 ok", result)
+	}
+	want := map[string]bool{
+		filepath.Join(p.Config.OutputDir, "a.jpg"):                 true,
+		filepath.Join(p.Config.OutputDir, "shoes", "men", "b.jpg"): true,
+	}
+	for _, path := range handler.saved {
+		if !want[path] {
+			t.Errorf("unexpected output %s", path)
+		}
+	}
+}
+
+func TestProcessFolderRenameFailure(t *testing.T) {
+	handler := &fakeHandler{failRenames: 1}
+	p, dir := newTestProcessor(t, handler, "a.jpg", "a.png")
+	p.Config.WithCollision(config.CollisionSuffix).Workers = 1
+
+	result, err := p.ProcessFolder(dir, "jpg", nil)
+	if err != nil {
+		t.Fatalf("ProcessFolder() error = %v", err)
+	}
+	if result.Count(StatusOK) != 1 || result.Count(StatusFailed) != 1 {
+		t.Fatalf("ProcessFolder() result = %v, want 1 ok, 1 failed", result)
+	}
+	entries, err := os.ReadDir(p.Config.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".goimgtool-") && e.Name() != JournalFile {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+	// The failed file gave up its name, so the other one gets it unsuffixed.
+	if want := filepath.Join(p.Config.OutputDir, "a.jpg"); !slices.Equal(handler.saved, []string{want}) {
+		t.Errorf("saved %v, want %s", handler.saved, want)
+	}
+}
+
+func TestProcessFolderCollisions(t *testing.T) {
+	tests := []struct {
+		policy      string
+		ok, skipped int
+		failed      int
+	}{
+		{policy: config.CollisionOverwrite, ok: 2},
+		{policy: config.CollisionSuffix, ok: 2},
+		{policy: config.CollisionSkip, ok: 1, skipped: 1},
+		{policy: config.CollisionFail, ok: 1, failed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			handler := &fakeHandler{}
+			p, dir := newTestProcessor(t, handler, "photo.jpg", "photo.png")
+			p.Config.WithCollision(tt.policy)
+
+			result, err := p.ProcessFolder(dir, "webp", nil)
+			if err != nil {
+				t.Fatalf("ProcessFolder() error = %v", err)
+			}
+			if result.Count(StatusOK) != tt.ok || result.Count(StatusSkipped) != tt.skipped || result.Count(StatusFailed) != tt.failed {
+				t.Errorf("ProcessFolder() result = %v", result)
+			}
+			if n := len(result.Collisions()); n != 1 {
+				t.Errorf("Collisions() = %d, want 1", n)
+			}
+			for _, f := range result.Failed() {
+				if !errors.Is(f.Err, ErrOutputExists) {
+					t.Errorf("failed file error = %v, want ErrOutputExists", f.Err)
+				}
+			}
+			if tt.policy == config.CollisionSuffix && !(contains(handler.saved, filepath.Join(p.Config.OutputDir, "photo_1.webp"))) {
+				t.Errorf("saved %v, want a photo_1.webp", handler.saved)
+			}
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProcessFolderIncremental(t *testing.T) {
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler, "a.jpg", "b.jpg")
+	p.Config.WithIncremental(true)
+
+	if result, err := p.ProcessFolder(dir, "jpg", nil); err != nil || result.Count(StatusOK) != 2 {
+		t.Fatalf("first run = %v, %v; want 2 ok", result, err)
+	}
+	result, err := p.ProcessFolder(dir, "jpg", nil)
+	if err != nil || result.Count(StatusSkipped) != 2 {
+		t.Fatalf("second run = %v, %v; want 2 skipped", result, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.jpg"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	result, err = p.ProcessFolder(dir, "jpg", nil)
+	if err != nil || result.Count(StatusOK) != 1 {
+		t.Fatalf("third run = %v, %v; want 1 ok", result, err)
+	}
+	bOut := filepath.Join(p.Config.OutputDir, "b.jpg")
+	if len(result.Pruned) != 1 || result.Pruned[0] != bOut {
+		t.Errorf("Pruned = %v, want [%s]", result.Pruned, bOut)
+	}
+	if _, err := os.Stat(bOut); !os.IsNotExist(err) {
+		t.Errorf("pruned output %s still exists", bOut)
+	}
+
+	p.WatermarkMode = "resize"
+	if result, err := p.ProcessFolder(dir, "jpg", nil); err != nil || result.Count(StatusOK) != 1 {
+		t.Errorf("run with changed settings = %v, %v; want 1 ok", result, err)
+	}
+}
+
+func TestProcessFolderAbandonedEvent(t *testing.T) {
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler, "a.jpg")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var last Event
+	_, err := p.ProcessFolderContext(ctx, dir, "jpg", func(ev Event) {
+		if ev.Type == FileStarted {
+			cancel()
+		}
+		last = ev
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ProcessFolderContext() error = %v, want context.Canceled", err)
+	}
+	if last.Type != FileSkipped || last.Err != nil {
+		t.Errorf("last event = %v, %v; want an abandoned file reported as skipped", last.Type, last.Err)
+	}
+}
+
+func TestResumeAfterCancel(t *testing.T) {
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler, "a.jpg", "b.jpg", "c.jpg")
+	p.WatermarkPath = "watermark.png"
+	p.Config.WithWorkers(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := p.ProcessFolderContext(ctx, dir, "jpg", func(ev Event) {
+		if ev.Type == FileFinished {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ProcessFolderContext() error = %v, want context.Canceled", err)
+	}
+
+	j, err := LoadJournal(p.Config.OutputDir)
+	if err != nil {
+		t.Fatalf("LoadJournal() error = %v", err)
+	}
+	if len(j.Done) != 1 || j.Format != "jpg" || j.Mode != "crop" {
+		t.Fatalf("LoadJournal() = %+v, want 1 done file", j)
+	}
+
+	resumed, err := NewResumeProcessor(j, handler)
+	if err != nil {
+		t.Fatalf("NewResumeProcessor() error = %v", err)
+	}
+	result, err := resumed.Resume(context.Background(), j, nil)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if result.Count(StatusOK) != 2 || result.Count(StatusSkipped) != 1 {
+		t.Errorf("Resume() result = %v, want 2 ok, 1 skipped", result)
+	}
+	if _, err := LoadJournal(p.Config.OutputDir); err == nil {
+		t.Error("journal still present after the resumed run completed")
+	}
+}
+
+func TestAnchorWatermark(t *testing.T) {
+	bounds := image.Rect(0, 0, 1000, 500)
+	tests := []struct {
+		anchor string
+		margin float64
+		unit   string
+		scale  float64
+		want   image.Rectangle
+	}{
+		// 20% of the shorter side is 100px for the watermark's longer side.
+		{config.AnchorBottomRight, 10, config.MarginPixels, 20, image.Rect(890, 440, 990, 490)},
+		{config.AnchorTopLeft, 2, config.MarginPercent, 20, image.Rect(10, 10, 110, 60)},
+		{config.AnchorCenter, 10, config.MarginPixels, 40, image.Rect(400, 200, 600, 300)},
+		{config.AnchorBottom, 0, config.MarginPixels, 0, image.Rect(400, 400, 600, 500)},
+		{config.AnchorLeft, 0, config.MarginPixels, 100, image.Rect(0, 125, 500, 375)},
+	}
+	for _, tt := range tests {
+		p := &ImageProcessor{
+			Watermark: image.NewNRGBA(image.Rect(0, 0, 200, 100)),
+			Config:    config.DefaultConfig().WithAnchor(tt.anchor, tt.margin, tt.unit, tt.scale),
+		}
+		if err := validateAnchor(p.Config); err != nil {
+			t.Fatalf("validateAnchor(%s): %v", tt.anchor, err)
+		}
+		pl, err := p.anchorWatermark(image.NewNRGBA(bounds))
+		if err != nil {
+			t.Fatalf("anchorWatermark(%s): %v", tt.anchor, err)
+		}
+		wm, at := pl.watermark, pl.at
+		if pl.anchor != tt.anchor {
+			t.Errorf("%s: placed at anchor %s", tt.anchor, pl.anchor)
+		}
+		if at != tt.want {
+			t.Errorf("%s: watermark at %v, want %v", tt.anchor, at, tt.want)
+		}
+		if wm.Bounds().Size() != at.Size() {
+			t.Errorf("%s: watermark size %v does not match %v", tt.anchor, wm.Bounds().Size(), at.Size())
+		}
+	}
+}
+
+func TestAutoAnchor(t *testing.T) {
+	// Noise everywhere except the top-left corner.
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			v := uint8(0)
+			if (x/2+y/2)%2 == 0 && (x >= 150 || y >= 100) {
+				v = 255
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	p := &ImageProcessor{
+		Watermark: image.NewNRGBA(image.Rect(0, 0, 100, 50)),
+		Config:    config.DefaultConfig().WithAnchor(config.AnchorAuto, 10, config.MarginPixels, 20),
+	}
+	if err := validateAnchor(p.Config); err != nil {
+		t.Fatalf("validateAnchor: %v", err)
+	}
+	pl, err := p.anchorWatermark(img)
+	if err != nil {
+		t.Fatalf("anchorWatermark: %v", err)
+	}
+	if pl.anchor != config.AnchorTopLeft {
+		t.Errorf("auto anchor chose %s, want %s", pl.anchor, config.AnchorTopLeft)
+	}
+	if want := image.Rect(10, 10, 70, 40); pl.at != want {
+		t.Errorf("watermark at %v, want %v", pl.at, want)
+	}
+}
+
+func TestTileWatermark(t *testing.T) {
+	wm := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(wm, wm.Bounds(), image.NewUniform(color.NRGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+	cfg := config.DefaultConfig().WithTile(10, 5, -3, false)
+	cfg.Scale = 0
+	p := &ImageProcessor{Watermark: wm, Config: cfg, WatermarkMode: "tile"}
+
+	bounds := image.Rect(0, 0, 100, 60)
+	tiled, err := p.tileWatermark(bounds)
+	if err != nil {
+		t.Fatalf("tileWatermark: %v", err)
+	}
+	if tiled.Bounds() != bounds {
+		t.Fatalf("tiled watermark bounds = %v, want %v", tiled.Bounds(), bounds)
+	}
+	// Tiles are 30x20 cells starting at (5, -3): (5, 0) is covered, the
+	// spacing at (25, 0) and (5, 7) is not.
+	for _, tt := range []struct {
+		x, y   int
+		opaque bool
+	}{{5, 0, true}, {24, 6, true}, {35, 17, true}, {25, 0, false}, {5, 7, false}, {0, 0, false}} {
+		_, _, _, a := tiled.At(tt.x, tt.y).RGBA()
+		if (a != 0) != tt.opaque {
+			t.Errorf("pixel (%d, %d) alpha = %d, want opaque=%v", tt.x, tt.y, a, tt.opaque)
+		}
+	}
+
+	p.Config.TileRotate = true
+	if rotated, err := p.tileWatermark(bounds); err != nil || rotated.Bounds().Size() != bounds.Size() {
+		t.Errorf("rotated tile size = %v, want %v", rotated.Bounds().Size(), bounds.Size())
+	}
+}
+
+func TestApplyWatermarkOpacity(t *testing.T) {
+	wm := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(wm, wm.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	for _, tt := range []struct {
+		opacity int
+		want    int
+	}{{100, 255}, {50, 127}, {0, 0}} {
+		p := &ImageProcessor{Watermark: wm, Config: config.DefaultConfig().WithOpacity(tt.opacity), WatermarkMode: "resize"}
+		out, _, err := p.applyWatermark(img)
+		if err != nil {
+			t.Fatalf("applyWatermark: %v", err)
+		}
+		if got := int(out.NRGBAAt(5, 5).R); got < tt.want-1 || got > tt.want+1 {
+			t.Errorf("opacity %d: red = %d, want about %d", tt.opacity, got, tt.want)
+		}
+	}
+}
+
+func TestTextWatermark(t *testing.T) {
+	cfg := config.DefaultConfig().WithText("© Brand 2026", "", 5, "#FF0000", config.TextEffectOutline)
+	p, err := NewTextProcessor(cfg, &fakeHandler{})
+	if err != nil {
+		t.Fatalf("NewTextProcessor: %v", err)
+	}
+	if err := p.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	// The text is rendered for each output, so a bigger image gets bigger text.
+	small, err := p.renderText(image.Rect(0, 0, 400, 300))
+	if err != nil {
+		t.Fatalf("renderText: %v", err)
+	}
+	large, err := p.renderText(image.Rect(0, 0, 1600, 1200))
+	if err != nil {
+		t.Fatalf("renderText: %v", err)
+	}
+	if large.Bounds().Dy() < 3*small.Bounds().Dy() {
+		t.Errorf("text heights %d and %d do not follow the image size", small.Bounds().Dy(), large.Bounds().Dy())
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	out, _, err := p.applyWatermark(img)
+	if err != nil {
+		t.Fatalf("applyWatermark: %v", err)
+	}
+	var red bool
+	for y := 0; y < 300 && !red; y++ {
+		for x := 0; x < 400; x++ {
+			if c := out.NRGBAAt(x, y); c.R == 255 && c.G == 0 && c.A == 255 {
+				red = true
+				break
+			}
+		}
+	}
+	if !red {
+		t.Error("no text pixels drawn")
+	}
+
+	p.WatermarkMode = "crop"
+	if err := p.validate(); err == nil {
+		t.Error("validate() accepted a text watermark in crop mode")
+	}
+}
+
+func TestSVGWatermark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logo.svg")
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 20"><rect x="10" y="5" width="20" height="10" fill="#ff0000"/></svg>`
+	if err := os.WriteFile(path, []byte(svg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewImageProcessor(path, config.DefaultConfig(), &fakeHandler{})
+	if err != nil {
+		t.Fatalf("NewImageProcessor: %v", err)
+	}
+	if got := p.Watermark.Bounds().Size(); got != image.Pt(40, 20) {
+		t.Errorf("watermark size = %v, want the viewBox size 40x20", got)
+	}
+
+	// Rasterized at 10x the viewBox, the rectangle edges stay sharp.
+	big := p.resizedWatermark(400, 200)
+	for _, tt := range []struct {
+		x, y  int
+		alpha uint32
+	}{{100, 50, 0xffff}, {299, 149, 0xffff}, {99, 50, 0}, {300, 150, 0}} {
+		if _, _, _, a := big.At(tt.x, tt.y).RGBA(); a != tt.alpha {
+			t.Errorf("pixel (%d, %d) alpha = %#x, want %#x", tt.x, tt.y, a, tt.alpha)
+		}
+	}
+}
+
+func TestApplyWatermarkBlendModes(t *testing.T) {
+	wm := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(wm, wm.Bounds(), image.NewUniform(color.NRGBA{128, 128, 128, 255}), image.Point{}, draw.Src)
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{200, 50, 0, 255}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		mode string
+		want color.NRGBA
+	}{
+		{config.BlendNormal, color.NRGBA{128, 128, 128, 255}},
+		{config.BlendMultiply, color.NRGBA{100, 25, 0, 255}},
+		{config.BlendScreen, color.NRGBA{228, 153, 128, 255}},
+		{config.BlendOverlay, color.NRGBA{200, 50, 0, 255}},
+		{config.BlendDifference, color.NRGBA{72, 78, 128, 255}},
+	}
+	for _, tt := range tests {
+		p := &ImageProcessor{Watermark: wm, Config: config.DefaultConfig().WithBlendMode(tt.mode), WatermarkMode: "resize"}
+		if err := p.validate(); err != nil {
+			t.Fatalf("validate(%s): %v", tt.mode, err)
+		}
+		out, _, err := p.applyWatermark(img)
+		if err != nil {
+			t.Fatalf("applyWatermark(%s): %v", tt.mode, err)
+		}
+		got := out.NRGBAAt(1, 1)
+		for _, d := range []int{int(got.R) - int(tt.want.R), int(got.G) - int(tt.want.G), int(got.B) - int(tt.want.B)} {
+			if d < -1 || d > 1 {
+				t.Errorf("%s: got %v, want %v", tt.mode, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAdaptiveWatermark(t *testing.T) {
+	wm := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(wm, wm.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	p := &ImageProcessor{Watermark: wm, Config: config.DefaultConfig().WithAdaptive(""), WatermarkMode: "resize"}
+
+	for _, tt := range []struct {
+		name       string
+		background color.Color
+		want       uint8
+	}{
+		{"bright photo gets the inverted logo", color.NRGBA{240, 230, 200, 255}, 0},
+		{"dark photo keeps the white logo", color.NRGBA{20, 20, 40, 255}, 255},
+	} {
+		img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+		draw.Draw(img, img.Bounds(), image.NewUniform(tt.background), image.Point{}, draw.Src)
+		out, _, err := p.applyWatermark(img)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := out.NRGBAAt(5, 5).R; got != tt.want {
+			t.Errorf("%s: red = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOrientationWatermarks(t *testing.T) {
+	cfg := config.DefaultConfig().WithOrientationWatermarks("portrait.png", "", "square.png")
+	p, err := NewImageProcessor("default.png", cfg, &fakeHandler{})
+	if err != nil {
+		t.Fatalf("NewImageProcessor: %v", err)
+	}
+	for _, tt := range []struct {
+		bounds image.Rectangle
+		want   string
+	}{
+		{image.Rect(0, 0, 600, 900), "portrait.png"},
+		{image.Rect(0, 0, 900, 600), "default.png"}, // no landscape asset
+		{image.Rect(0, 0, 1000, 990), "square.png"},
+		{image.Rect(0, 0, 1000, 970), "default.png"},
+	} {
+		if got := p.sourceFor(tt.bounds).WatermarkPath; got != tt.want {
+			t.Errorf("%v (%s): watermark %s, want %s", tt.bounds.Size(), orientation(tt.bounds), got, tt.want)
+		}
+	}
+}
+
+func TestWatermarkLayers(t *testing.T) {
+	logo := config.NewLayer("logo.png", "anchor")
+	logo.Anchor, logo.Margin, logo.Scale = config.AnchorTopLeft, 0, 50
+	logo.BlendMode = config.BlendDifference
+	cfg := config.DefaultConfig().WithLayers(logo)
+	p, err := NewImageProcessor("frame.png", cfg, &fakeHandler{})
+	if err != nil {
+		t.Fatalf("NewImageProcessor: %v", err)
+	}
+	p.WatermarkMode = "resize"
+	if err := p.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	// The main watermark turns the black image white, then the logo layer
+	// inverts its own corner again.
+	nrgba, _, err := p.applyWatermark(image.NewNRGBA(image.Rect(0, 0, 100, 100)))
+	if err != nil {
+		t.Fatalf("applyWatermark: %v", err)
+	}
+	if c := nrgba.NRGBAAt(1, 1); c.R != 0 {
+		t.Errorf("pixel under the logo = %v, want black", c)
+	}
+	if c := nrgba.NRGBAAt(90, 90); c.R != 255 {
+		t.Errorf("pixel outside the logo = %v, want white", c)
+	}
+
+	p.layers[0].WatermarkMode = "stretch"
+	if err := p.validate(); err == nil {
+		t.Error("validate() accepted a layer with an unknown mode")
+	}
+}
+
+func TestForensicMark(t *testing.T) {
+	// A smooth gradient with some texture, like a photo.
+	img := image.NewNRGBA(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			v := uint8(40 + x/2 + (x*y)%23)
+			img.SetNRGBA(x, y, color.NRGBA{v, uint8(60 + y/2), 120, 255})
+		}
+	}
+	mark := ForensicMark{Owner: "acme-studio", ImageID: forensicImageID("shoot/a.jpg")}
+	if err := embedForensic(img, mark); err != nil {
+		t.Fatalf("embedForensic: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 75}); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ExtractForensic(decoded)
+	if err != nil || got != mark {
+		t.Errorf("after JPEG: got %v, %v; want %v", got, err, mark)
+	}
+
+	cropped := img.SubImage(image.Rect(37, 21, 300, 230))
+	if got, err := ExtractForensic(cropped); err != nil || got != mark {
+		t.Errorf("after crop: got %v, %v; want %v", got, err, mark)
+	}
+
+	if _, err := ExtractForensic(image.NewNRGBA(image.Rect(0, 0, 320, 240))); !errors.Is(err, ErrNoForensicMark) {
+		t.Errorf("unmarked image: got %v, want ErrNoForensicMark", err)
+	}
+}
+
+func TestForensicMarkTightTargetSize(t *testing.T) {
+	// A noisy photo-like image that only fits a small target at a quality
+	// the mark doesn't survive.
+	img := image.NewNRGBA(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			n := (x*7919 + y*104729 + x*y*31) % 61
+			img.SetNRGBA(x, y, color.NRGBA{uint8(40 + x/4 + n), uint8(60 + y/3 + n/2), uint8(100 + n), 255})
+		}
+	}
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.jpg"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.NewConfig(640, 480, "jpg", 80).
+		WithOutput(filepath.Join(t.TempDir(), "out"), "{name}.{ext}").
+		WithForensic("acme-studio")
+	cfg.TargetSizeKB = 20
+	p := &ImageProcessor{Watermark: image.NewNRGBA(image.Rect(0, 0, 8, 8)), Config: cfg, WatermarkMode: "crop", FileHandler: &fileio.Handler{}}
+
+	result, err := p.ProcessFolder(dir, "jpg", nil)
+	if err != nil {
+		t.Fatalf("ProcessFolder() error = %v", err)
+	}
+	f := result.Files[0]
+	if f.Quality < ForensicMinQuality {
+		t.Errorf("encoded at quality %d, below %d", f.Quality, ForensicMinQuality)
+	}
+	if f.Status == StatusOK || !errors.Is(f.Err, fileio.ErrTargetSizeExceeded) {
+		t.Errorf("status %s, error %v; want the missed target reported", f.Status, f.Err)
+	}
+	out, err := fileio.LoadImage(f.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mark, err := ExtractForensic(out); err != nil || mark.Owner != "acme-studio" {
+		t.Errorf("ExtractForensic() = %v, %v; want owner acme-studio", mark, err)
+	}
+
+	cfg.MaxQuality = 40
+	if err := p.validate(); err == nil {
+		t.Error("validate() accepted a maximum quality the mark doesn't survive")
+	}
+}
+
+func TestProcessFolderRecipients(t *testing.T) {
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler, "a.jpg", "b.jpg")
+	p.Config.WithText("Preview for "+config.RecipientToken, "", 5, "#FFFFFF", config.TextEffectNone).
+		WithRecipients(config.Recipient{ID: "agency-a", Name: "Agency A"}, config.Recipient{ID: "agency-b"})
+	p.WatermarkMode = "anchor"
+
+	var last Event
+	result, err := p.ProcessFolder(dir, "webp", func(ev Event) { last = ev })
+	if err != nil {
+		t.Fatalf("ProcessFolder() error = %v", err)
+	}
+	if got := result.Count(StatusOK); got != 4 {
+		t.Fatalf("%d files ok, want 4: %+v", got, result.Files)
+	}
+	if last.Current != 4 || last.Total != 4 {
+		t.Errorf("last event %d/%d, want 4/4", last.Current, last.Total)
+	}
+	out := p.OutputDirFor(dir)
+	for _, id := range []string{"agency-a", "agency-b"} {
+		for _, name := range []string{"a.webp", "b.webp"} {
+			if !handler.Exists(filepath.Join(out, id, name)) {
+				t.Errorf("missing output %s/%s", id, name)
+			}
+		}
+	}
+}
+
+func TestProcessFolderRecipientsRelativeInput(t *testing.T) {
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler, "photos/a.jpg")
+	t.Chdir(dir)
+	p.Config.WithOutput("", "{name}.{ext}").
+		WithText(config.RecipientToken, "", 5, "#FFFFFF", config.TextEffectNone).
+		WithRecipients(config.Recipient{ID: "agency-a"})
+	p.WatermarkMode = "anchor"
+
+	result, err := p.ProcessFolder("photos", "webp", nil)
+	if err != nil {
+		t.Fatalf("ProcessFolder() error = %v", err)
+	}
+	if got := result.Count(StatusOK); got != 1 {
+		t.Fatalf("%d files ok, want 1: %+v", got, result.Files)
+	}
+	want := filepath.Join(p.OutputDirFor("photos"), "agency-a", "a.webp")
+	if !handler.Exists(want) {
+		t.Errorf("missing output %s, saved %v", want, handler.saved)
+	}
+}
+
+func TestProcessFolderRecipientsRecursive(t *testing.T) {
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler, "a.jpg", "shoes/b.jpg", "shoes/c.jpg")
+	p.Config.WithOutput("", "{name}.{ext}").WithRecursive(0).
+		WithText(config.RecipientToken, "", 5, "#FFFFFF", config.TextEffectNone).
+		WithRecipients(config.Recipient{ID: "agency-a"}, config.Recipient{ID: "agency-b"})
+	p.WatermarkMode = "anchor"
+
+	result, err := p.ProcessFolder(dir, "webp", nil)
+	if err != nil {
+		t.Fatalf("ProcessFolder() error = %v", err)
+	}
+	// Recipient b must not pick up the exports of recipient a.
+	if got := result.Count(StatusOK); got != 6 {
+		t.Fatalf("%d files ok, want 6: %v", got, handler.saved)
+	}
+}
+
+// cancelRecipientExport starts a recursive export for three recipients and
+// cancels it after the first file of the second one.
+func cancelRecipientExport(t *testing.T) (*fakeHandler, string, string) {
+	t.Helper()
+	handler := &fakeHandler{}
+	p, dir := newTestProcessor(t, handler, "a.jpg", "shoes/b.jpg")
+	p.Config.WithOutput("", "{name}.{ext}").WithRecursive(0).WithWorkers(1).
+		WithText(config.RecipientToken, "", 5, "#FFFFFF", config.TextEffectNone).
+		WithRecipients(config.Recipient{ID: "agency-a"}, config.Recipient{ID: "agency-b"}, config.Recipient{ID: "agency-c"})
+	p.WatermarkMode = "anchor"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	finished := 0
+	_, err := p.ProcessFolderContext(ctx, dir, "jpg", func(ev Event) {
+		if ev.Type == FileFinished {
+			if finished++; finished == 3 {
+				cancel()
+			}
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ProcessFolderContext() error = %v, want context.Canceled", err)
+	}
+	handler.saved = nil
+	return handler, dir, p.OutputDirFor(dir)
+}
+
+func TestResumeRecipientExport(t *testing.T) {
+	handler, _, out := cancelRecipientExport(t)
+
+	j, err := LoadJournal(out)
+	if err != nil {
+		t.Fatalf("LoadJournal() error = %v", err)
+	}
+	if !j.DoneRecipients["agency-a"] || len(j.DoneRecipients) != 1 {
+		t.Fatalf("done recipients = %v, want agency-a", j.DoneRecipients)
+	}
+	p, err := NewResumeProcessor(j, handler)
+	if err != nil {
+		t.Fatalf("NewResumeProcessor() error = %v", err)
+	}
+	result, err := p.Resume(context.Background(), j, nil)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	// The rest of agency-b, then all of agency-c.
+	if result.Count(StatusOK) != 3 || result.Count(StatusSkipped) != 1 {
+		t.Errorf("Resume() result = %v, want 3 ok, 1 skipped", result)
+	}
+	for _, id := range []string{"agency-a", "agency-b", "agency-c"} {
+		for _, name := range []string{"a.jpg", filepath.Join("shoes", "b.jpg")} {
+			if !handler.Exists(filepath.Join(out, id, name)) {
+				t.Errorf("missing output %s/%s", id, name)
+			}
+		}
+	}
+	if _, err := LoadJournal(out); err == nil {
+		t.Error("journal still present after the resumed export completed")
+	}
+}
+
+func TestResumeOneRecipient(t *testing.T) {
+	handler, _, out := cancelRecipientExport(t)
+
+	// Resuming a recipient's own folder must not walk into the others.
+	j, err := LoadJournal(filepath.Join(out, "agency-b"))
+	if err != nil {
+		t.Fatalf("LoadJournal() error = %v", err)
+	}
+	p, err := NewResumeProcessor(j, handler)
+	if err != nil {
+		t.Fatalf("NewResumeProcessor() error = %v", err)
+	}
+	if _, err := p.Resume(context.Background(), j, nil); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	want := filepath.Join(out, "agency-b", "shoes", "b.jpg")
+	if !slices.Equal(handler.saved, []string{want}) {
+		t.Errorf("saved %v, want %s", handler.saved, want)
+	}
+}
+
+func TestResizeImageFill(t *testing.T) {
+	// Flat gray on the left half, noise on the right half.
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			v := uint8(128)
+			if x >= 200 {
+				v = uint8((x*7919 + y*104729) % 251)
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	for _, anchor := range config.CropAnchors {
+		p := &ImageProcessor{Config: config.NewConfig(100, 100, "jpg", 80).WithFill(anchor)}
+		if err := validateResize(p.Config); err != nil {
+			t.Fatalf("validateResize(%s): %v", anchor, err)
+		}
+		out, err := p.resizeImage(img)
+		if err != nil {
+			t.Fatalf("resizeImage(%s): %v", anchor, err)
+		}
+		if size := out.Bounds().Size(); size != image.Pt(100, 100) {
+			t.Errorf("%s: output is %v, want 100x100", anchor, size)
+		}
+		if anchor != config.CropAuto {
+			continue
+		}
+		// The auto crop keeps the noisy half, so the left edge isn't flat.
+		lo, hi := uint32(0xffff), uint32(0)
+		for y := 0; y < 100; y++ {
+			r, _, _, _ := out.At(out.Bounds().Min.X+2, out.Bounds().Min.Y+y).RGBA()
+			lo, hi = min(lo, r), max(hi, r)
+		}
+		if hi-lo < 0x1000 {
+			t.Errorf("auto crop kept the flat half")
+		}
+	}
+}
+
+func TestValidateResizeRenditions(t *testing.T) {
+	cfg := config.NewConfig(800, 600, "jpg", 80).WithFill(config.CropCenter)
+	cfg.WithRenditions(config.Rendition{Width: 320}, config.Rendition{Height: 600})
+	if err := validateResize(cfg); err != nil {
+		t.Errorf("validateResize() rejected renditions within the canvas: %v", err)
+	}
+	cfg.WithRenditions(config.Rendition{Width: 320}, config.Rendition{Width: 1920})
+	if err := validateResize(cfg); err == nil {
+		t.Error("validateResize() accepted a rendition wider than the canvas")
+	}
+}
+
+func TestResizeImagePad(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	draw.Draw(img, img.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	for _, background := range []string{"#000000", config.PadBlur} {
+		p := &ImageProcessor{Config: config.NewConfig(100, 100, "jpg", 80).WithPad(background)}
+		if err := validateResize(p.Config); err != nil {
+			t.Fatalf("validateResize(%s): %v", background, err)
+		}
+		out, err := p.resizeImage(img)
+		if err != nil {
+			t.Fatalf("resizeImage(%s): %v", background, err)
+		}
+		if size := out.Bounds().Size(); size != image.Pt(100, 100) {
+			t.Fatalf("%s: output is %v, want 100x100", background, size)
+		}
+		// The image fills the middle band; the padding above it is either
+		// the color or the blurred image.
+		want := map[string]color.NRGBA{"#000000": {0, 0, 0, 255}, config.PadBlur: red}[background]
+		if got := color.NRGBAModel.Convert(out.At(50, 5)).(color.NRGBA); got != want {
+			t.Errorf("%s: padding is %v, want %v", background, got, want)
+		}
+		if got := color.NRGBAModel.Convert(out.At(50, 50)).(color.NRGBA); got != red {
+			t.Errorf("%s: image area is %v, want %v", background, got, red)
+		}
+	}
+}
+
+func TestProcessFolderRenditions(t *testing.T) {
+	handler := &fakeHandler{imgSize: image.Pt(400, 300)}
+	p, dir := newTestProcessor(t, handler, "a.jpg")
+	p.Config.WithMaxQuality(80).WithRenditions(
+		config.Rendition{Width: 320, TargetSizeKB: 50},
+		config.Rendition{Width: 160, TargetSizeKB: 20, MaxQuality: 70},
+		config.Rendition{Height: 100},
+	)
+
+	result, err := p.ProcessFolder(dir, "webp", nil)
+	if err != nil {
+		t.Fatalf("ProcessFolder() error = %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Status != StatusOK {
+		t.Fatalf("result = %+v", result.Files)
+	}
+	out := p.OutputDirFor(dir)
+	want := []Output{
+		{Path: filepath.Join(out, "a-320w.webp"), Width: 320, Height: 240},
+		{Path: filepath.Join(out, "a-160w.webp"), Width: 160, Height: 120},
+		{Path: filepath.Join(out, "a-100h.webp"), Width: 133, Height: 100},
+	}
+	got := result.Files[0].Renditions
+	if len(got) != len(want) {
+		t.Fatalf("renditions = %+v, want %d", got, len(want))
+	}
+	for i, w := range want {
+		if got[i].Path != w.Path || got[i].Width != w.Width || got[i].Height != w.Height {
+			t.Errorf("rendition %d = %+v, want %s at %dx%d", i, got[i], w.Path, w.Width, w.Height)
+		}
+		if !handler.Exists(w.Path) {
+			t.Errorf("missing output %s", w.Path)
+		}
+	}
+	if result.Files[0].OutputPath != want[0].Path {
+		t.Errorf("OutputPath = %s, want %s", result.Files[0].OutputPath, want[0].Path)
+	}
+	// A rendition's own maximum replaces the global one; the others keep it.
+	for i, q := range []int{80, 70, 80} {
+		if got := handler.configs[i].MaxQuality; got != q {
+			t.Errorf("rendition %d encoded with MaxQuality %d, want %d", i, got, q)
+		}
+	}
+}
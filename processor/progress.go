@@ -0,0 +1,46 @@
+package processor
+
+// EventType identifies the stage of a file in a batch run.
+type EventType int
+
+const (
+	FileStarted EventType = iota
+	FileFinished
+	FileFailed
+	FileSkipped
+)
+
+func (t EventType) String() string {
+	switch t {
+	case FileStarted:
+		return "started"
+	case FileFinished:
+		return "finished"
+	case FileFailed:
+		return "failed"
+	case FileSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+// Event reports the progress of a single file. Output fields are only set
+// once the file has been written.
+type Event struct {
+	Type       EventType
+	Current    int // files completed so far, including failures
+	Total      int
+	FileName   string
+	OutputPath string
+	Width      int
+	Height     int
+	Quality    int
+	SizeBytes  int64
+	Err        error
+}
+
+// ProgressCallback receives batch events. It is called from worker
+// goroutines, but never concurrently. Workers wait while it runs, so it
+// should hand slow work, like decoding the output, to another goroutine.
+type ProgressCallback func(Event)
@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/del1x/GoIMGtool/config"
+)
+
+// RecipientError records the recipient whose export a run stopped at.
+type RecipientError struct {
+	Recipient string
+	OutputDir string // the recipient's output folder
+	Err       error
+}
+
+func (e *RecipientError) Error() string {
+	return fmt.Sprintf("recipient %s: %v", e.Recipient, e.Err)
+}
+
+func (e *RecipientError) Unwrap() error {
+	return e.Err
+}
+
+// processRecipients runs the batch once for every entry of
+// Config.Recipients, each into its own subfolder of the output folder with
+// a config from Config.ForRecipient. Progress counts across all exports;
+// the result holds the files of every export. A journal in the output
+// folder records the exports that finished; with resume set, those are
+// skipped and the interrupted one continues from its own journal.
+func (p *ImageProcessor) processRecipients(ctx context.Context, imageDir, outputFormat string, resume *Journal, progress ProgressCallback) (*BatchResult, error) {
+	startTime := time.Now()
+	// The recipient folders are absolute so that the sub-processors, which
+	// resolve their output folder against imageDir again, don't nest a
+	// relative output folder twice.
+	outputDir := p.OutputDirFor(imageDir)
+	if abs, err := filepath.Abs(outputDir); err == nil {
+		outputDir = abs
+	}
+	r := &run{imageDir: imageDir, outputDir: outputDir, format: outputFormat, started: startTime}
+	if err := p.FileHandler.CreateDir(outputDir); err != nil {
+		return nil, err
+	}
+	var j *journal
+	var err error
+	if resume != nil {
+		j, err = appendJournal(outputDir)
+	} else {
+		j, err = createJournal(outputDir, p.journalHeader(r))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := p.Config.Recipients
+	result := &BatchResult{}
+	fail := func(rc config.Recipient, dir string, err error) (*BatchResult, error) {
+		// Only a cancelled export can be resumed; any other error would
+		// stop the resumed run the same way.
+		if ctx.Err() != nil {
+			j.close()
+		} else {
+			j.finish()
+		}
+		result.Elapsed = time.Since(startTime)
+		return result, &RecipientError{Recipient: rc.ID, OutputDir: dir, Err: err}
+	}
+	for i, rc := range recipients {
+		if resume != nil && resume.DoneRecipients[rc.ID] {
+			continue
+		}
+		cfg := p.Config.ForRecipient(rc)
+		cfg.OutputDir = filepath.Join(outputDir, rc.ID)
+		rp, err := p.recipientProcessor(cfg)
+		if err != nil {
+			return fail(rc, cfg.OutputDir, err)
+		}
+		rp.recipientsDir = outputDir
+		var report ProgressCallback
+		if progress != nil {
+			report = func(e Event) {
+				e.Current += i * e.Total
+				e.Total *= len(recipients)
+				progress(e)
+			}
+		}
+		var sub *Journal
+		if resume != nil {
+			// Without a journal of its own, the export had not started.
+			sub, _ = LoadJournal(cfg.OutputDir)
+		}
+		res, err := rp.processFolder(ctx, imageDir, outputFormat, sub, report)
+		if res != nil {
+			result.Files = append(result.Files, res.Files...)
+			result.Pruned = append(result.Pruned, res.Pruned...)
+			result.Cancelled = result.Cancelled || res.Cancelled
+		}
+		if err != nil {
+			return fail(rc, cfg.OutputDir, err)
+		}
+		j.recordRecipient(rc.ID)
+	}
+	j.finish()
+	result.Elapsed = time.Since(startTime)
+	return result, nil
+}
+
+// recipientProcessor loads the watermarks of p again for cfg, whose texts
+// differ from those of p.
+func (p *ImageProcessor) recipientProcessor(cfg *config.Config) (*ImageProcessor, error) {
+	var rp *ImageProcessor
+	var err error
+	if cfg.Text != "" {
+		rp, err = NewTextProcessor(cfg, p.FileHandler)
+	} else {
+		rp, err = NewImageProcessor(p.WatermarkPath, cfg, p.FileHandler)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rp.WatermarkMode = p.WatermarkMode
+	return rp, nil
+}
@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"image"
+
+	"github.com/del1x/GoIMGtool/config"
+	"github.com/disintegration/imaging"
+)
+
+// rendition is one encoded size of a source.
+type rendition struct {
+	img    *image.NRGBA
+	cfg    *config.Config // output settings for the encoder
+	suffix string         // added to the output name before the extension
+}
+
+// renditions scales the watermarked image to every size in
+// Config.Renditions, or returns it as the only rendition without them.
+func (p *ImageProcessor) renditions(img *image.NRGBA) []rendition {
+	if len(p.Config.Renditions) == 0 {
+		return []rendition{{img: img, cfg: p.encoderConfig(*p.Config)}}
+	}
+	bounds := img.Bounds()
+	out := make([]rendition, 0, len(p.Config.Renditions))
+	for _, r := range p.Config.Renditions {
+		cfg := *p.Config
+		cfg.MaxWidth, cfg.MaxHeight = r.Width, r.Height
+		if cfg.MaxWidth == 0 {
+			cfg.MaxWidth = bounds.Dx()
+		}
+		if cfg.MaxHeight == 0 {
+			cfg.MaxHeight = bounds.Dy()
+		}
+		// The image already has its final shape; the encoder must not
+		// crop or pad it again.
+		cfg.ResizeMode = config.ResizeFit
+		if r.TargetSizeKB > 0 {
+			cfg.TargetSizeKB = r.TargetSizeKB
+		}
+		if r.MaxQuality > 0 {
+			cfg.MaxQuality = r.MaxQuality
+		}
+		out = append(out, rendition{
+			img:    imaging.Fit(img, cfg.MaxWidth, cfg.MaxHeight, imaging.Lanczos),
+			cfg:    p.encoderConfig(cfg),
+			suffix: r.Suffix(),
+		})
+	}
+	return out
+}
+
+// encoderConfig keeps the size optimizer from picking a quality the
+// invisible watermark doesn't survive.
+func (p *ImageProcessor) encoderConfig(cfg config.Config) *config.Config {
+	if cfg.ForensicOwner != "" {
+		cfg.MinQuality = max(cfg.MinQuality, ForensicMinQuality)
+	}
+	return &cfg
+}
+
+// renditionBounds is the size the source is scaled to before it is
+// watermarked: the smallest box that holds every rendition.
+func (p *ImageProcessor) renditionBounds() (width, height int) {
+	const unbounded = 1 << 30
+	for _, r := range p.Config.Renditions {
+		width, height = max(width, r.Width), max(height, r.Height)
+		if r.Width == 0 {
+			width = unbounded
+		}
+		if r.Height == 0 {
+			height = unbounded
+		}
+	}
+	return width, height
+}
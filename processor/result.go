@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+)
+
+// FileStatus is the outcome of a single file in a batch run.
+type FileStatus int
+
+const (
+	StatusOK FileStatus = iota
+	StatusSkipped
+	StatusFailed
+)
+
+func (s FileStatus) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusSkipped:
+		return "skipped"
+	case StatusFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// FileError records the pipeline stage a file failed in.
+type FileError struct {
+	Op   string // load, resize, watermark or save
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// FileResult describes what happened to one input file.
+type FileResult struct {
+	InputPath  string
+	OutputPath string
+	Status     FileStatus
+	Reason     string // why the file was skipped
+	Err        error  // a *FileError when Status is StatusFailed
+	// CollidedWith is the existing file or earlier input whose output name
+	// this file clashed with, empty when there was no collision.
+	CollidedWith string
+	Width        int
+	Height       int
+	Quality      int
+	Size         int64 // bytes
+	// Anchors lists the anchor each watermark drawn in the anchor mode was
+	// placed at, the main watermark first and then its layers.
+	Anchors []string
+	ImageID string // image ID in the invisible watermark, if one was embedded
+	// Renditions lists every file written with Config.Renditions; the
+	// output fields above describe the first of them.
+	Renditions []Output
+	Duration   time.Duration
+}
+
+// Output is one file written for a source.
+type Output struct {
+	Path    string
+	Width   int
+	Height  int
+	Quality int
+	Size    int64 // bytes
+}
+
+// BatchResult collects the per-file results of a batch run.
+type BatchResult struct {
+	Files     []FileResult
+	Pruned    []string // outputs removed because their source is gone
+	Elapsed   time.Duration
+	Cancelled bool
+}
+
+// Count returns the number of files with the given status.
+func (r *BatchResult) Count(status FileStatus) int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the results of the files that could not be processed.
+func (r *BatchResult) Failed() []FileResult {
+	var failed []FileResult
+	for _, f := range r.Files {
+		if f.Status == StatusFailed {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// Collisions returns the results of the files whose output name clashed
+// with another file, whatever the collision policy did about it.
+func (r *BatchResult) Collisions() []FileResult {
+	var collided []FileResult
+	for _, f := range r.Files {
+		if f.CollidedWith != "" {
+			collided = append(collided, f)
+		}
+	}
+	return collided
+}
+
+func (r *BatchResult) String() string {
+	s := fmt.Sprintf("%d ok, %d skipped, %d failed", r.Count(StatusOK), r.Count(StatusSkipped), r.Count(StatusFailed))
+	if n := len(r.Collisions()); n > 0 {
+		s += fmt.Sprintf(", %d name collisions", n)
+	}
+	if n := len(r.Pruned); n > 0 {
+		s += fmt.Sprintf(", %d pruned", n)
+	}
+	return s + fmt.Sprintf(" in %v", r.Elapsed.Round(time.Millisecond))
+}
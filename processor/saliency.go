@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"image"
+	"math"
+
+	"github.com/del1x/GoIMGtool/config"
+	"github.com/disintegration/imaging"
+)
+
+// autoCandidates are the anchors AnchorAuto chooses from, in order of
+// preference when several are equally quiet. The center is left out: it
+// is where the subject usually is.
+var autoCandidates = []string{
+	config.AnchorBottomRight, config.AnchorBottomLeft,
+	config.AnchorTopRight, config.AnchorTopLeft,
+	config.AnchorBottom, config.AnchorTop,
+	config.AnchorRight, config.AnchorLeft,
+}
+
+// edgeMapSize is the longer side of the image the edge energy is measured
+// on. Busy and quiet regions are large features, so a thumbnail is enough.
+const edgeMapSize = 160
+
+// edgeMap holds the gradient energy of a downscaled image as a summed-area
+// table, so the mean energy of any rectangle costs four lookups.
+type edgeMap struct {
+	bounds image.Rectangle // of the original image
+	w, h   int
+	sum    []float64 // (w+1)*(h+1) prefix sums
+}
+
+func newEdgeMap(img image.Image) *edgeMap {
+	bounds := img.Bounds()
+	small := imaging.Grayscale(imaging.Fit(img, edgeMapSize, edgeMapSize, imaging.Box))
+	w, h := small.Bounds().Dx(), small.Bounds().Dy()
+	gray := func(x, y int) float64 {
+		return float64(small.Pix[y*small.Stride+x*4])
+	}
+	m := &edgeMap{bounds: bounds, w: w, h: h, sum: make([]float64, (w+1)*(h+1))}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var e float64
+			if x+1 < w {
+				e += math.Abs(gray(x+1, y) - gray(x, y))
+			}
+			if y+1 < h {
+				e += math.Abs(gray(x, y+1) - gray(x, y))
+			}
+			i := (y+1)*(w+1) + x + 1
+			m.sum[i] = e + m.sum[i-1] + m.sum[i-w-1] - m.sum[i-w-2]
+		}
+	}
+	return m
+}
+
+// mean is the average edge energy inside r, given in the coordinates of
+// the original image.
+func (m *edgeMap) mean(r image.Rectangle) float64 {
+	r = r.Intersect(m.bounds).Sub(m.bounds.Min)
+	sx := float64(m.w) / float64(m.bounds.Dx())
+	sy := float64(m.h) / float64(m.bounds.Dy())
+	x0, y0 := int(float64(r.Min.X)*sx), int(float64(r.Min.Y)*sy)
+	x1 := min(m.w, max(x0+1, int(math.Ceil(float64(r.Max.X)*sx))))
+	y1 := min(m.h, max(y0+1, int(math.Ceil(float64(r.Max.Y)*sy))))
+	if x0 >= x1 || y0 >= y1 {
+		return 0
+	}
+	at := func(x, y int) float64 { return m.sum[y*(m.w+1)+x] }
+	total := at(x1, y1) - at(x0, y1) - at(x1, y0) + at(x0, y0)
+	return total / float64((x1-x0)*(y1-y0))
+}
+
+// quietestAnchor returns the candidate anchor where a watermark of the
+// given size would cover the least edge energy of img.
+func quietestAnchor(img image.Image, size image.Point, margin int) string {
+	edges := newEdgeMap(img)
+	best, bestEnergy := autoCandidates[0], math.Inf(1)
+	for _, anchor := range autoCandidates {
+		energy := edges.mean(anchorRect(anchor, img.Bounds(), size, margin))
+		if energy < bestEnergy {
+			best, bestEnergy = anchor, energy
+		}
+	}
+	return best
+}
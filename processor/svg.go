@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+func isSVG(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".svg")
+}
+
+// loadSVG parses the vector watermark at path. It is kept as a vector and
+// rasterized at the size each output needs.
+func (p *ImageProcessor) loadSVG(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error loading watermark: %v", err)
+	}
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error parsing SVG watermark: %v", err)
+	}
+	if icon.ViewBox.W <= 0 || icon.ViewBox.H <= 0 {
+		return fmt.Errorf("SVG watermark %s has no size, set its viewBox or width and height", path)
+	}
+	sum := sha256.Sum256(data)
+	p.svg = icon
+	p.sourceSum = hex.EncodeToString(sum[:])
+	// The raster at the SVG's own size stands in wherever the code needs
+	// the watermark's dimensions or the crop mode cuts it without scaling.
+	w := max(1, int(math.Round(icon.ViewBox.W)))
+	h := max(1, int(math.Round(icon.ViewBox.H)))
+	p.Watermark = p.rasterizeSVG(w, h)
+	return nil
+}
+
+// rasterizeSVG draws the SVG watermark into a w x h image.
+func (p *ImageProcessor) rasterizeSVG(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	scanner := rasterx.NewScannerGV(w, h, img, img.Bounds())
+	dasher := rasterx.NewDasher(w, h, scanner)
+	// Drawing updates the transform stored in the icon and its paths, so
+	// workers take turns.
+	p.svgMu.Lock()
+	defer p.svgMu.Unlock()
+	p.svg.SetTarget(0, 0, float64(w), float64(h))
+	p.svg.Draw(dasher, 1)
+	return img
+}
+
+// resizedWatermark returns the watermark at w x h, rasterizing SVG
+// watermarks at that size instead of resampling a bitmap.
+func (p *ImageProcessor) resizedWatermark(w, h int) image.Image {
+	if p.svg != nil {
+		return p.rasterizeSVG(w, h)
+	}
+	return imaging.Resize(p.Watermark, w, h, imaging.Lanczos)
+}
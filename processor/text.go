@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+
+	"github.com/del1x/GoIMGtool/config"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// NewTextProcessor creates a processor that draws cfg.Text instead of a
+// watermark image.
+func NewTextProcessor(cfg *config.Config, fileHandler FileHandler) (*ImageProcessor, error) {
+	if cfg.Text == "" {
+		return nil, fmt.Errorf("watermark text is empty")
+	}
+	data := goregular.TTF
+	if cfg.FontPath != "" {
+		var err error
+		if data, err = os.ReadFile(cfg.FontPath); err != nil {
+			return nil, fmt.Errorf("error reading font: %v", err)
+		}
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing font %s: %v", cfg.FontPath, err)
+	}
+	sum := sha256.Sum256(data)
+	p := &ImageProcessor{
+		Font:          f,
+		sourceSum:     hex.EncodeToString(sum[:]),
+		Config:        cfg,
+		WatermarkMode: "anchor",
+		FileHandler:   fileHandler,
+	}
+	if err := p.loadVariants(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func validateText(cfg *config.Config, mode string) error {
+	if mode != "anchor" && mode != "tile" {
+		return fmt.Errorf("text watermarks need the anchor or tile mode, not %q", mode)
+	}
+	if cfg.Text == "" {
+		return fmt.Errorf("watermark text is empty")
+	}
+	if cfg.TextSize <= 0 || cfg.TextSize > 100 {
+		return fmt.Errorf("text size must be between 0 and 100 percent")
+	}
+	if _, err := config.ParseColor(cfg.TextColor); err != nil {
+		return err
+	}
+	switch cfg.TextEffect {
+	case config.TextEffectNone, config.TextEffectOutline, config.TextEffectShadow:
+		return nil
+	default:
+		return fmt.Errorf("unknown text effect %q", cfg.TextEffect)
+	}
+}
+
+// renderText draws Config.Text for an image with the given bounds. The font
+// size follows the image, so every output gets text rendered at its own
+// resolution rather than a scaled bitmap.
+func (p *ImageProcessor) renderText(bounds image.Rectangle) (image.Image, error) {
+	cfg := p.Config
+	size := math.Max(4, float64(min(bounds.Dx(), bounds.Dy()))*cfg.TextSize/100)
+	face, err := opentype.NewFace(p.Font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, fmt.Errorf("error creating font face: %v", err)
+	}
+	defer face.Close()
+	textColor, err := config.ParseColor(cfg.TextColor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copies of the text drawn behind it in effectColor, shifted by offsets.
+	var offsets []image.Point
+	var effectColor color.NRGBA
+	switch cfg.TextEffect {
+	case config.TextEffectOutline:
+		r := math.Max(1, size/16)
+		for i := range 16 {
+			a := float64(i) * math.Pi / 8
+			offsets = append(offsets, image.Pt(int(math.Round(r*math.Cos(a))), int(math.Round(r*math.Sin(a)))))
+		}
+		effectColor = contrastColor(textColor)
+	case config.TextEffectShadow:
+		d := int(math.Max(1, size/20))
+		offsets = []image.Point{{d, d}}
+		effectColor = color.NRGBA{A: textColor.A / 2}
+	}
+	pad := 0
+	for _, off := range offsets {
+		pad = max(pad, abs(off.X), abs(off.Y))
+	}
+
+	b, advance := font.BoundString(face, cfg.Text)
+	minX, maxX := b.Min.X.Floor(), max(b.Max.X.Ceil(), advance.Ceil())
+	minY, maxY := b.Min.Y.Floor(), b.Max.Y.Ceil()
+	if maxX <= minX || maxY <= minY {
+		return nil, fmt.Errorf("watermark text %q has no visible glyphs", cfg.Text)
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, maxX-minX+2*pad, maxY-minY+2*pad))
+	origin := fixed.P(pad-minX, pad-minY)
+	d := &font.Drawer{Dst: canvas, Face: face}
+	if len(offsets) > 0 {
+		d.Src = image.NewUniform(effectColor)
+		for _, off := range offsets {
+			d.Dot = origin.Add(fixed.P(off.X, off.Y))
+			d.DrawString(cfg.Text)
+		}
+	}
+	d.Src = image.NewUniform(textColor)
+	d.Dot = origin
+	d.DrawString(cfg.Text)
+	return canvas, nil
+}
+
+// contrastColor returns black for light colors and white for dark ones,
+// keeping the alpha of c.
+func contrastColor(c color.NRGBA) color.NRGBA {
+	if 299*int(c.R)+587*int(c.G)+114*int(c.B) > 128*1000 {
+		return color.NRGBA{A: c.A}
+	}
+	return color.NRGBA{255, 255, 255, c.A}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
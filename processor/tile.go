@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/del1x/GoIMGtool/config"
+	"github.com/disintegration/imaging"
+)
+
+func validateTile(cfg *config.Config) error {
+	if cfg.TileSpacing < 0 {
+		return fmt.Errorf("tile spacing must not be negative")
+	}
+	return validateScale(cfg)
+}
+
+// tileWatermark repeats the scaled watermark across an image of the given
+// bounds. The result has the image's size and is transparent between tiles.
+func (p *ImageProcessor) tileWatermark(bounds image.Rectangle) (image.Image, error) {
+	tile, err := p.watermarkFor(bounds)
+	if err != nil {
+		return nil, err
+	}
+	width, height := bounds.Dx(), bounds.Dy()
+	if !p.Config.TileRotate {
+		return p.tilePattern(tile, width, height), nil
+	}
+
+	// Tile a square that still covers the image once it is turned, then cut
+	// the image-sized middle out of the rotated pattern.
+	side := int(math.Ceil(math.Hypot(float64(width), float64(height))))
+	rotated := imaging.Rotate(p.tilePattern(tile, side, side), 45, color.Transparent)
+	x := (rotated.Bounds().Dx() - width) / 2
+	y := (rotated.Bounds().Dy() - height) / 2
+	return imaging.Crop(rotated, image.Rect(x, y, x+width, y+height)), nil
+}
+
+// tilePattern fills a width x height canvas with copies of tile, starting
+// from the configured offset.
+func (p *ImageProcessor) tilePattern(tile image.Image, width, height int) *image.NRGBA {
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	stepX := tile.Bounds().Dx() + p.Config.TileSpacing
+	stepY := tile.Bounds().Dy() + p.Config.TileSpacing
+	startX := mod(p.Config.TileOffsetX, stepX) - stepX
+	startY := mod(p.Config.TileOffsetY, stepY) - stepY
+	for y := startY; y < height; y += stepY {
+		for x := startX; x < width; x += stepX {
+			at := image.Rect(x, y, x+tile.Bounds().Dx(), y+tile.Bounds().Dy())
+			draw.Draw(canvas, at, tile, tile.Bounds().Min, draw.Over)
+		}
+	}
+	return canvas
+}
+
+// mod is the remainder of a/b with the sign of b.
+func mod(a, b int) int {
+	return (a%b + b) % b
+}
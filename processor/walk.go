@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// collectImages lists the images to process as paths relative to imageDir,
+// along with the files that were skipped. Subdirectories are only walked
+// when Config.Recursive is set; the output directory, and during a
+// recipients run the folder holding every recipient's output, is never
+// walked.
+func (p *ImageProcessor) collectImages(r *run) ([]string, []FileResult, error) {
+	imageDir := r.imageDir
+	var files []string
+	var skipped []FileResult
+	outputDir, _ := filepath.Abs(r.outputDir)
+
+	var walk func(rel string, depth int) error
+	walk = func(rel string, depth int) error {
+		dir := filepath.Join(imageDir, rel)
+		entries, err := p.FileHandler.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("error reading directory: %v", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			entryRel := filepath.Join(rel, name)
+			path := filepath.Join(imageDir, entryRel)
+			if entry.IsDir() {
+				if !p.Config.Recursive || (p.Config.MaxDepth > 0 && depth >= p.Config.MaxDepth) {
+					continue
+				}
+				if p.Config.SkipHidden && strings.HasPrefix(name, ".") {
+					continue
+				}
+				if abs, err := filepath.Abs(path); err == nil && (abs == outputDir || abs == p.recipientsDir) {
+					continue
+				}
+				if err := walk(entryRel, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			if p.isWatermarkFile(name) {
+				fmt.Println("Skipping watermark.png")
+				skipped = append(skipped, FileResult{InputPath: path, Status: StatusSkipped, Reason: "watermark file"})
+				continue
+			}
+			if !p.isSupportedExtension(name) {
+				fmt.Printf("Skipping file %s: unsupported extension %s\n", entryRel, filepath.Ext(name))
+				skipped = append(skipped, FileResult{InputPath: path, Status: StatusSkipped, Reason: "unsupported extension " + filepath.Ext(name)})
+				continue
+			}
+			files = append(files, entryRel)
+		}
+		return nil
+	}
+
+	if err := walk("", 0); err != nil {
+		return nil, nil, err
+	}
+	return files, skipped, nil
+}
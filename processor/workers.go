@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// imageSizer is implemented by file handlers that can read image dimensions
+// without decoding the pixels.
+type imageSizer interface {
+	ImageSize(path string) (int, int, error)
+}
+
+// bytesPerPixel is the size of one NRGBA pixel.
+const bytesPerPixel = 4
+
+// workerCount returns how many files may be processed in parallel. It starts
+// from Config.Workers and, when a memory budget is set, lowers it so that the
+// largest image in the batch fits the budget that many times over.
+func (p *ImageProcessor) workerCount(imageDir string, names []string) int {
+	workers := p.Config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(names) && len(names) > 0 {
+		workers = len(names)
+	}
+	if p.Config.MemoryBudgetMB <= 0 {
+		return workers
+	}
+	sizer, ok := p.FileHandler.(imageSizer)
+	if !ok {
+		return workers
+	}
+
+	var largest int64
+	for _, name := range names {
+		width, height, err := sizer.ImageSize(filepath.Join(imageDir, name))
+		if err != nil {
+			continue
+		}
+		if est := p.estimateMemory(width, height); est > largest {
+			largest = est
+		}
+	}
+	if largest == 0 {
+		return workers
+	}
+
+	budget := int64(p.Config.MemoryBudgetMB) << 20
+	fit := int(budget / largest)
+	if fit < 1 {
+		fit = 1
+	}
+	if fit < workers {
+		fmt.Printf("Limiting workers to %d to stay within %d MB\n", fit, p.Config.MemoryBudgetMB)
+		workers = fit
+	}
+	return workers
+}
+
+// estimateMemory approximates the peak memory needed for one file: the
+// decoded source plus the resized image, the prepared watermark, its
+// transparent copy and the composited result.
+func (p *ImageProcessor) estimateMemory(width, height int) int64 {
+	src := int64(width) * int64(height)
+	out := src
+	if limit := int64(p.Config.MaxWidth) * int64(p.Config.MaxHeight); limit > 0 && limit < out {
+		out = limit
+	}
+	return (src + 4*out) * bytesPerPixel
+}